@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+const (
+	defaultRecentKeys = 20
+	maxRecentKeys     = 1000
+)
+
+type summary struct {
+	Name     string `json:"name"`
+	Policy   string `json:"policy,omitempty"`
+	Used     uint   `json:"used"`
+	Capacity uint   `json:"capacity"`
+	Items    int    `json:"items"`
+}
+
+type detail struct {
+	summary
+	Stats      *cache.Stats `json:"stats,omitempty"`
+	RecentKeys []string     `json:"recentKeys"`
+}
+
+type handler struct {
+	registry *Registry
+}
+
+// NewHandler serves the caches in the registry. Mount it with http.StripPrefix on your admin port:
+//
+//	GET  /                   lists every cache with its policy and capacity usage
+//	GET  /{name}             details for one cache, including the most recently used keys (?keys=20)
+//	GET  /{name}/stats       the cache's Stats
+//	POST /{name}/invalidate  invalidates the key in the "key" form value
+//	POST /{name}/clear       removes everything from the cache
+//
+// Every response is JSON. Cache names must not contain "/"
+func NewHandler(registry *Registry) http.Handler {
+	return &handler{
+		registry: registry,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		h.list(w, r)
+		return
+	}
+	segments := strings.Split(path, "/")
+	if len(segments) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	name := segments[0]
+	c, ok := h.registry.lookup(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no cache named '%s'", name), http.StatusNotFound)
+		return
+	}
+	action := ""
+	if len(segments) == 2 {
+		action = segments[1]
+	}
+	switch action {
+	case "":
+		h.detail(w, r, name, c)
+	case "stats":
+		h.stats(w, r, c)
+	case "invalidate":
+		h.invalidate(w, r, c)
+	case "clear":
+		h.clear(w, r, c)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *handler) list(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	summaries := make([]summary, 0)
+	for _, name := range h.registry.names() {
+		if c, ok := h.registry.lookup(name); ok {
+			summaries = append(summaries, summarize(name, c.cache))
+		}
+	}
+	writeJSON(w, summaries)
+}
+
+func (h *handler) detail(w http.ResponseWriter, r *http.Request, name string, c registered) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	max := defaultRecentKeys
+	if raw := r.URL.Query().Get("keys"); raw != "" {
+		var err error
+		max, err = strconv.Atoi(raw)
+		if err != nil || max < 0 {
+			http.Error(w, "keys must be a positive number", http.StatusBadRequest)
+			return
+		}
+		if max > maxRecentKeys {
+			max = maxRecentKeys
+		}
+	}
+	d := detail{
+		summary:    summarize(name, c.cache),
+		RecentKeys: make([]string, 0),
+	}
+	if inspector, ok := c.cache.(cache.Inspector); ok {
+		stats := inspector.Stats()
+		d.Stats = &stats
+		for _, key := range inspector.RecentKeys(max) {
+			d.RecentKeys = append(d.RecentKeys, fmt.Sprint(key))
+		}
+	}
+	writeJSON(w, d)
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request, c registered) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	inspector, ok := c.cache.(cache.Inspector)
+	if !ok {
+		http.Error(w, "cache does not report stats", http.StatusNotImplemented)
+		return
+	}
+	writeJSON(w, inspector.Stats())
+}
+
+func (h *handler) invalidate(w http.ResponseWriter, r *http.Request, c registered) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	raw := r.FormValue("key")
+	if raw == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	key, err := c.parseKey(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.cache.Invalidate(key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) clear(w http.ResponseWriter, r *http.Request, c registered) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	clearer, ok := c.cache.(cache.Clearer)
+	if !ok {
+		http.Error(w, "cache cannot be cleared", http.StatusNotImplemented)
+		return
+	}
+	clearer.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func summarize(name string, c cache.Invalidater) (s summary) {
+	s.Name = name
+	if inspector, ok := c.(cache.Inspector); ok {
+		s.Policy = inspector.Policy()
+		s.Used, s.Capacity = inspector.Usage()
+		s.Items = inspector.Stats().Items
+	}
+	return
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
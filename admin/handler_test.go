@@ -0,0 +1,123 @@
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/admin"
+)
+
+func echo(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return key, nil
+}
+
+func getJSON(server *httptest.Server, path string, v interface{}) int {
+	resp, err := http.Get(server.URL + path)
+	Expect(err).ShouldNot(HaveOccurred())
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK {
+		Expect(json.NewDecoder(resp.Body).Decode(v)).Should(Succeed())
+	}
+	return resp.StatusCode
+}
+
+func post(server *httptest.Server, path string, form url.Values) int {
+	resp, err := http.PostForm(server.URL+path, form)
+	Expect(err).ShouldNot(HaveOccurred())
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		ctx      context.Context
+		items    cache.GetInvalidater
+		numbers  cache.GetInvalidater
+		registry *admin.Registry
+		server   *httptest.Server
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		items = cache.NewLRUItem(3, echo)
+		numbers = cache.NewUnbounded(echo)
+		registry = admin.NewRegistry()
+		registry.Register("items", items, nil)
+		registry.Register("numbers", numbers, func(raw string) (key interface{}, err error) {
+			return strconv.Atoi(raw)
+		})
+		server = httptest.NewServer(admin.NewHandler(registry))
+
+		for _, key := range []string{"a", "b", "c", "d", "b"} {
+			_, _ = items.Get(ctx, key)
+		}
+		_, _ = numbers.Get(ctx, 42)
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("lists registered caches", func() {
+		var summaries []map[string]interface{}
+		Expect(getJSON(server, "/", &summaries)).Should(Equal(http.StatusOK))
+		Expect(summaries).Should(HaveLen(2))
+		Expect(summaries[0]).Should(HaveKeyWithValue("name", "items"))
+		Expect(summaries[0]).Should(HaveKeyWithValue("policy", "lru"))
+		Expect(summaries[0]).Should(HaveKeyWithValue("used", BeEquivalentTo(3)))
+		Expect(summaries[0]).Should(HaveKeyWithValue("capacity", BeEquivalentTo(3)))
+		Expect(summaries[1]).Should(HaveKeyWithValue("policy", "unbounded"))
+	})
+
+	It("shows keys in recency order", func() {
+		var detail struct {
+			RecentKeys []string `json:"recentKeys"`
+		}
+		Expect(getJSON(server, "/items?keys=2", &detail)).Should(Equal(http.StatusOK))
+		Expect(detail.RecentKeys).Should(Equal([]string{"b", "d"}))
+	})
+
+	It("exposes stats", func() {
+		var stats cache.Stats
+		Expect(getJSON(server, "/items/stats", &stats)).Should(Equal(http.StatusOK))
+		Expect(stats).Should(Equal(cache.Stats{
+			Hits:      1,
+			Misses:    4,
+			Evictions: 1,
+			Items:     3,
+		}))
+	})
+
+	It("invalidates keys", func() {
+		Expect(post(server, "/numbers/invalidate", url.Values{"key": {"42"}})).Should(Equal(http.StatusNoContent))
+		Expect(numbers.(cache.Inspector).Stats().Items).Should(BeZero())
+	})
+
+	It("rejects keys that cannot be parsed", func() {
+		Expect(post(server, "/numbers/invalidate", url.Values{"key": {"forty-two"}})).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("clears caches", func() {
+		Expect(post(server, "/items/clear", nil)).Should(Equal(http.StatusNoContent))
+		used, _ := items.(cache.Inspector).Usage()
+		Expect(used).Should(BeZero())
+	})
+
+	It("only changes caches through POST", func() {
+		resp, err := http.Get(server.URL + "/items/clear")
+		Expect(err).ShouldNot(HaveOccurred())
+		_ = resp.Body.Close()
+		Expect(resp.StatusCode).Should(Equal(http.StatusMethodNotAllowed))
+		Expect(items.(cache.Inspector).Stats().Items).Should(Equal(3))
+	})
+
+	It("does not find unknown caches", func() {
+		var ignored interface{}
+		Expect(getJSON(server, "/missing", &ignored)).Should(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+// KeyParser converts a key typed into the admin page into the key the cache uses
+type KeyParser func(raw string) (key interface{}, err error)
+
+// StringKey uses the raw string as-is, this is the default KeyParser
+func StringKey(raw string) (key interface{}, err error) {
+	return raw, nil
+}
+
+type registered struct {
+	cache    cache.Invalidater
+	parseKey KeyParser
+}
+
+// Registry holds the caches that are visible through the admin Handler
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]registered
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		caches: make(map[string]registered),
+	}
+}
+
+// Register makes the cache visible under name, replacing any cache already registered with that name.
+// Caches only need to be Invalidaters, but are much more useful if they are also cache.Inspector and cache.Clearer.
+// parseKey may be nil, in which case keys are assumed to be strings
+func (r *Registry) Register(name string, c cache.Invalidater, parseKey KeyParser) {
+	if parseKey == nil {
+		parseKey = StringKey
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches[name] = registered{
+		cache:    c,
+		parseKey: parseKey,
+	}
+}
+
+// Unregister removes the cache from view. It does not change the cache itself
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.caches, name)
+}
+
+func (r *Registry) lookup(name string) (c registered, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok = r.caches[name]
+	return
+}
+
+func (r *Registry) names() (names []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,43 @@
+package cache
+
+// annotated is a loaded value along with what its loader told the cache about it
+type annotated struct {
+	value     interface{}
+	tags      []interface{}
+	namespace interface{}
+	dependsOn []interface{}
+
+	// expiresAt is in Unix nanoseconds, zero to never expire
+	expiresAt int64
+}
+
+// unannotater is implemented by the caches that take the annotations off what their ValueMapper loads, rather than
+// passing them on to their callers
+type unannotater interface {
+	unannotates()
+}
+
+func (u *unbounded) unannotates()    {}
+func (s *shardedCache) unannotates() {}
+func (r *remote) unannotates()       {}
+
+// annotate the value, starting from a copy of its annotations if it already has some
+func annotate(value interface{}) *annotated {
+	if a, ok := value.(*annotated); ok {
+		copied := *a
+		return &copied
+	}
+	return &annotated{
+		value: value,
+	}
+}
+
+// unannotate separates the value from its annotations. notes is never nil
+func unannotate(value interface{}) (bare interface{}, notes *annotated) {
+	if a, ok := value.(*annotated); ok {
+		return a.value, a
+	}
+	return value, &annotated{
+		value: value,
+	}
+}
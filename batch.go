@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type batch struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	keys   []interface{}
+	index  map[interface{}]bool
+	timer  *time.Timer
+
+	// waiters is how many callers are still waiting on the batch. Guarded by the batcher's mu
+	waiters int
+
+	// done is closed once values and err are set
+	done   chan struct{}
+	values map[interface{}]interface{}
+	err    error
+}
+
+// batchContext has the values of the first caller's ctx, but is only done once every caller in the batch has given
+// up on it, or the batch is loaded
+type batchContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c batchContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+type batcher struct {
+	bulkMapper BulkValueMapper
+	wait       time.Duration
+	maxBatch   int
+
+	mu      sync.Mutex
+	pending *batch
+}
+
+// NewBatchingMapper gathers the keys from concurrent ValueMapper calls into batches, loading each batch with one
+// call to bulkMapper. Use it as the ValueMapper of any cache to turn the misses of many goroutines, each calling
+// Get for a single key, into bulk loads without changing the callers.
+//
+// wait: how long the first key in a batch waits for others to join it
+// maxBatch: batches are loaded as soon as they reach this many keys, zero for no limit
+//
+// The bulkMapper is called with a ctx carrying the values of the first caller's ctx, which is only cancelled once
+// every caller in the batch has stopped waiting, so one caller timing out does not fail the others. It has no
+// deadline of its own. Callers whose ctx is done before the batch is loaded stop waiting, and a batch every caller
+// has given up on is not loaded at all
+func NewBatchingMapper(bulkMapper BulkValueMapper, wait time.Duration, maxBatch int) ValueMapper {
+	b := &batcher{
+		bulkMapper: bulkMapper,
+		wait:       wait,
+		maxBatch:   maxBatch,
+	}
+	return b.load
+}
+
+func (b *batcher) load(ctx context.Context, key interface{}) (value interface{}, err error) {
+	current := b.join(ctx, key)
+	select {
+	case <-current.done:
+		return valueFromBulk(key, current.values, current.err)
+	case <-ctx.Done():
+		b.leave(current)
+		return nil, ctx.Err()
+	}
+}
+
+// join adds the key to the pending batch, starting one if there is none, and starts loading it once it is full
+func (b *batcher) join(ctx context.Context, key interface{}) (current *batch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	current = b.pending
+	if current == nil {
+		batchCtx, cancel := context.WithCancel(context.Background())
+		current = &batch{
+			ctx:    batchContext{Context: batchCtx, values: ctx},
+			cancel: cancel,
+			index:  make(map[interface{}]bool),
+			done:   make(chan struct{}),
+		}
+		current.timer = time.AfterFunc(b.wait, func() {
+			b.dispatch(current)
+		})
+		b.pending = current
+	}
+	current.waiters++
+	if !current.index[key] {
+		current.index[key] = true
+		current.keys = append(current.keys, key)
+	}
+	if b.maxBatch > 0 && len(current.keys) >= b.maxBatch {
+		current.timer.Stop()
+		b.pending = nil
+		go b.run(current)
+	}
+	return
+}
+
+// leave stops a caller waiting on the batch, cancelling it once nobody is
+func (b *batcher) leave(current *batch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	current.waiters--
+	if current.waiters > 0 {
+		return
+	}
+	if b.pending == current {
+		current.timer.Stop()
+		b.pending = nil
+	}
+	current.cancel()
+}
+
+// dispatch loads the batch when its wait is over, unless it already filled up and was loaded
+func (b *batcher) dispatch(current *batch) {
+	if b.take(current) {
+		b.run(current)
+	}
+}
+
+// take the batch off pending, true if it was still there
+func (b *batcher) take(current *batch) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending != current {
+		return false
+	}
+	b.pending = nil
+	return true
+}
+
+func (b *batcher) run(current *batch) {
+	defer current.cancel()
+	if err := current.ctx.Err(); err != nil {
+		current.err = err
+	} else {
+		current.values, current.err = safeBulkLoad(current.ctx, b.bulkMapper, current.keys)
+	}
+	close(current.done)
+}
@@ -0,0 +1,146 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// getConcurrently calls Get for every key, each from its own goroutine, and waits for them all
+func getConcurrently(subject cache.Getter, keys ...interface{}) (errs map[interface{}]error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs = make(map[interface{}]error)
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key interface{}) {
+			defer GinkgoRecover()
+			defer wg.Done()
+			value, err := subject.Get(ignoreCtx, key)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[key] = err
+				return
+			}
+			Expect(value).Should(Equal(key))
+		}(key)
+	}
+	wg.Wait()
+	return
+}
+
+var _ = Describe("BatchingMapper", func() {
+	var (
+		source *recordingBulkMapper
+		keys   []interface{}
+	)
+	BeforeEach(func() {
+		source = &recordingBulkMapper{
+			failures: make(cache.KeyErrors),
+		}
+		keys = nil
+		for i := 0; i < 10; i++ {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	})
+
+	When("misses arrive within the wait", func() {
+		It("loads them together", func() {
+			subject := cache.NewLRUItem(100, cache.NewBatchingMapper(source.Load, 100*time.Millisecond, 0))
+			Expect(getConcurrently(subject, keys...)).Should(BeEmpty())
+			Expect(source.calls).Should(HaveLen(1))
+			Expect(source.calls[0]).Should(ConsistOf(keys...))
+		})
+		It("loads duplicate keys once", func() {
+			subject := cache.NewBatchingMapper(source.Load, 100*time.Millisecond, 0)
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(subject(ignoreCtx, "same")).Should(Equal("same"))
+				}()
+			}
+			wg.Wait()
+			Expect(source.calls).Should(Equal([][]interface{}{{"same"}}))
+		})
+	})
+
+	When("batches fill up", func() {
+		It("loads them without waiting", func() {
+			subject := cache.NewLRUItem(100, cache.NewBatchingMapper(source.Load, time.Hour, 5))
+			Expect(getConcurrently(subject, keys...)).Should(BeEmpty())
+			Expect(source.calls).Should(HaveLen(2))
+			Expect(source.calls[0]).Should(HaveLen(5))
+			Expect(source.calls[1]).Should(HaveLen(5))
+		})
+	})
+
+	When("some keys fail", func() {
+		BeforeEach(func() {
+			source.failures["3"] = intentionalErr
+			source.failures["4"] = nil
+		})
+		It("fails only those callers", func() {
+			subject := cache.NewLRUItem(100, cache.NewBatchingMapper(source.Load, 100*time.Millisecond, 0))
+			Expect(getConcurrently(subject, keys...)).Should(Equal(map[interface{}]error{
+				"3": intentionalErr,
+				"4": cache.ErrNotLoaded,
+			}))
+		})
+	})
+
+	When("the caller gives up", func() {
+		It("stops waiting", func() {
+			subject := cache.NewBatchingMapper(source.Load, time.Hour, 0)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := subject(ctx, "1")
+			Expect(err).Should(MatchError(context.Canceled))
+		})
+		It("still loads the batch for the other callers", func() {
+			type ctxKey struct{}
+			subject := cache.NewBatchingMapper(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				Expect(ctx.Value(ctxKey{})).Should(Equal("first"))
+				return source.Load(ctx, keys)
+			}, 50*time.Millisecond, 0)
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "first"))
+			first := make(chan error)
+			go func() {
+				_, err := subject(ctx, "1")
+				first <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			second := make(chan interface{})
+			go func() {
+				defer GinkgoRecover()
+				value, err := subject(ignoreCtx, "2")
+				Expect(err).ShouldNot(HaveOccurred())
+				second <- value
+			}()
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+			Expect(<-first).Should(MatchError(context.Canceled))
+			Expect(<-second).Should(Equal("2"))
+		})
+		It("does not load a batch every caller gave up on", func() {
+			subject := cache.NewBatchingMapper(source.Load, 20*time.Millisecond, 0)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+			defer cancel()
+			_, err := subject(ctx, "1")
+			Expect(err).Should(MatchError(context.DeadlineExceeded))
+			time.Sleep(40 * time.Millisecond)
+			Expect(source.calls).Should(BeEmpty())
+			Expect(subject(ignoreCtx, "2")).Should(Equal("2"))
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open")
+
+// BreakerPolicy controls when NewCircuitBreakerMapper stops calling an unhealthy backend
+type BreakerPolicy struct {
+	// FailureThreshold is how many loads in a row must fail to open the circuit, less than 1 is treated as 1
+	FailureThreshold int
+
+	// OpenFor is how long the circuit stays open before a single trial load is let through.
+	// If the trial succeeds the circuit closes, otherwise it stays open for another OpenFor
+	OpenFor time.Duration
+
+	// IsFailure is true for errors that count against the backend's health, nil uses IsTransient
+	IsFailure ErrorClassifier
+
+	// StaleItems is how many of the most recently loaded values are remembered, so they can be served
+	// while the circuit is open. Zero never serves stale values. They are remembered apart from the cache, so they
+	// use memory on top of it, and keep values alive after the cache has evicted or invalidated them
+	StaleItems uint
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type breaker struct {
+	valueMapper ValueMapper
+	policy      BreakerPolicy
+	stale       *lruBase
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+
+	// trialling is true while the one load let through by a half open circuit is running
+	trialling bool
+}
+
+// NewCircuitBreakerMapper fails loads fast with ErrCircuitOpen when the valueMapper keeps failing, instead of
+// waiting on a backend that is down. While the circuit is open, the last value loaded for the key is served
+// instead, if the policy remembers stale values and still has one
+func NewCircuitBreakerMapper(valueMapper ValueMapper, policy BreakerPolicy) ValueMapper {
+	if policy.FailureThreshold < 1 {
+		policy.FailureThreshold = 1
+	}
+	if policy.IsFailure == nil {
+		policy.IsFailure = IsTransient
+	}
+	b := &breaker{
+		valueMapper: valueMapper,
+		policy:      policy,
+	}
+	if policy.StaleItems > 0 {
+		b.stale = newLRU(policy.StaleItems, func(value interface{}) uint {
+			return 1
+		}, nil)
+	}
+	return b.load
+}
+
+func (b *breaker) load(ctx context.Context, key interface{}) (value interface{}, err error) {
+	allowed, trial := b.allow()
+	if !allowed {
+		if b.stale != nil {
+			if value, ok := b.stale.peek(key); ok {
+				return value, nil
+			}
+		}
+		return nil, ErrCircuitOpen
+	}
+	value, err = safeLoad(ctx, b.valueMapper, key)
+	b.record(err, trial)
+	if err == nil && b.stale != nil {
+		_ = b.stale.set(key, value)
+	}
+	return
+}
+
+// allow is true if the load may call the valueMapper. trial is true if it is the one load let through to
+// find out whether the backend has recovered
+func (b *breaker) allow() (allowed bool, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		return true, false
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenFor {
+			return false, false
+		}
+		b.state = breakerHalfOpen
+	}
+	if b.trialling {
+		return false, false
+	}
+	b.trialling = true
+	return true, true
+}
+
+func (b *breaker) record(err error, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failed := err != nil && b.policy.IsFailure(err)
+	if trial {
+		b.trialling = false
+	}
+	switch {
+	case trial && failed:
+		b.open()
+	case trial && err != nil:
+		// such as the caller giving up, which says nothing about the backend, so the next load is the trial
+	case trial:
+		b.state = breakerClosed
+		b.failures = 0
+	case b.state != breakerClosed:
+		// loads that started before the circuit opened don't change anything
+	case failed:
+		b.failures++
+		if b.failures >= b.policy.FailureThreshold {
+			b.open()
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+func (b *breaker) open() {
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+}
@@ -0,0 +1,95 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"time"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		healthy bool
+		calls   int
+		subject cache.ValueMapper
+	)
+	BeforeEach(func() {
+		healthy = true
+		calls = 0
+		subject = cache.NewCircuitBreakerMapper(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			calls++
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if !healthy {
+				return nil, intentionalErr
+			}
+			return key, nil
+		}, cache.BreakerPolicy{
+			FailureThreshold: 2,
+			OpenFor:          20 * time.Millisecond,
+			StaleItems:       10,
+		})
+	})
+
+	When("the backend fails", func() {
+		BeforeEach(func() {
+			Expect(subject(ignoreCtx, "cached")).Should(Equal("cached"))
+			healthy = false
+			_, _ = subject(ignoreCtx, "1")
+			_, _ = subject(ignoreCtx, "1")
+			calls = 0
+		})
+		It("fails fast", func() {
+			_, err := subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(cache.ErrCircuitOpen))
+			Expect(calls).Should(BeZero())
+		})
+		It("serves stale values", func() {
+			Expect(subject(ignoreCtx, "cached")).Should(Equal("cached"))
+			Expect(calls).Should(BeZero())
+		})
+		It("closes once the backend recovers", func() {
+			healthy = true
+			Eventually(func() error {
+				_, err := subject(ignoreCtx, "1")
+				return err
+			}).Should(Succeed())
+			Expect(calls).Should(Equal(1))
+			Expect(subject(ignoreCtx, "2")).Should(Equal("2"))
+		})
+		It("stays open if the trial fails", func() {
+			time.Sleep(25 * time.Millisecond)
+			_, err := subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(intentionalErr))
+			_, err = subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(cache.ErrCircuitOpen))
+			Expect(calls).Should(Equal(1))
+		})
+		It("stays half open if the caller gives up on the trial", func() {
+			time.Sleep(25 * time.Millisecond)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := subject(ctx, "1")
+			Expect(err).Should(MatchError(context.Canceled))
+			_, err = subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(intentionalErr))
+			_, err = subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(cache.ErrCircuitOpen))
+			Expect(calls).Should(Equal(2))
+		})
+	})
+
+	When("failures are not consecutive", func() {
+		It("stays closed", func() {
+			healthy = false
+			_, _ = subject(ignoreCtx, "1")
+			healthy = true
+			_, _ = subject(ignoreCtx, "1")
+			healthy = false
+			_, err := subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(intentionalErr))
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+var ErrNotLoaded = fmt.Errorf("bulk loader did not return a value for the key")
+
+/*
+BulkValueMapper loads many uncached values in a single call, for backends that support multi-key fetches
+
+ctx: the context passed to GetMany calls by the caller
+keys: only the keys that were not already cached, never empty and without duplicates
+values: the loaded values. Keys that are left out will fail with ErrNotLoaded
+err: fails every key, unless it is a KeyErrors, which fails only the keys it contains
+*/
+type BulkValueMapper func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error)
+
+type ManyGetter interface {
+	/*
+		GetMany gets many values from the cache at once. Cached values are returned immediately and all of the
+		missing values are loaded with a single call to the BulkValueMapper
+
+		values contains every key that could be obtained
+		err is a KeyErrors with the reason each of the remaining keys could not be obtained
+	*/
+	GetMany(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error)
+}
+
+type ManyGetInvalidater interface {
+	GetInvalidater
+	ManyGetter
+}
+
+// KeyErrors holds the reason each key could not be loaded
+type KeyErrors map[interface{}]error
+
+func (k KeyErrors) Error() string {
+	for key, err := range k {
+		if len(k) == 1 {
+			return fmt.Sprintf("failed to load '%v': %s", key, err)
+		}
+		return fmt.Sprintf("failed to load %d keys, including '%v': %s", len(k), key, err)
+	}
+	return "failed to load keys"
+}
+
+// NewUnboundedBulk is NewUnbounded, but loads misses in bulk. Get loads its key with a single key bulk call
+func NewUnboundedBulk(bulkMapper BulkValueMapper) ManyGetInvalidater {
+	u := newUnbounded(valueMapperFromBulk(bulkMapper))
+	u.bulkFactory = bulkMapper
+	return u
+}
+
+// NewLRUBulk is NewLRU, but loads misses in bulk. Get loads its key with a single key bulk call
+func NewLRUBulk(cap uint, valueSizer ValueSizer, bulkMapper BulkValueMapper) ManyGetInvalidater {
+	l := newLRU(cap, valueSizer, valueMapperFromBulk(bulkMapper))
+	l.bulkFactory = bulkMapper
+	return l
+}
+
+// GetMany for caches created with a ValueMapper calls it once for each missing key
+func (u *unbounded) GetMany(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+	values = make(map[interface{}]interface{}, len(keys))
+	flights := make(map[interface{}]*flight)
+	waiting := make(map[interface{}]*flight)
+
+	missing := u.hitsOrTakeOffs(keys, values, flights, waiting)
+
+	failed := make(KeyErrors)
+	if len(missing) != 0 {
+		loaded, loadErr := safeBulkLoad(ctx, u.bulkFactory, missing)
+		u.landMany(missing, flights, loaded, loadErr, values, failed)
+	}
+
+	for key, f := range waiting {
+		value, keyErr := f.wait(ctx)
+		if keyErr != nil {
+			failed[key] = keyErr
+			continue
+		}
+		values[key] = value
+	}
+
+	if len(failed) != 0 {
+		err = failed
+	}
+	return
+}
+
+// hitsOrTakeOffs sorts the keys into the values already cached, the flights the caller must load and the flights
+// it must wait for, returning the keys to load. If a key or hook panics, the flights already taken off are landed
+// with the panic, so nobody waits on them forever, and the lock is released
+func (u *unbounded) hitsOrTakeOffs(keys []interface{}, values map[interface{}]interface{}, flights, waiting map[interface{}]*flight) (missing []interface{}) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err := &LoaderPanicError{Value: r, Stack: debug.Stack()}
+			for key, f := range flights {
+				_, _ = u.landLocked(key, f, nil, err)
+			}
+			panic(r)
+		}
+	}()
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if _, ok := flights[key]; ok {
+			continue
+		}
+		if _, ok := waiting[key]; ok {
+			continue
+		}
+		if value, ok := u.lookupLocked(key); ok {
+			u.stats.Hits++
+			u.onHit(key)
+			values[key] = value
+			continue
+		}
+		u.stats.Misses++
+		f, isNew := u.takeOffLocked(key)
+		if !isNew {
+			waiting[key] = f
+			continue
+		}
+		flights[key] = f
+		missing = append(missing, key)
+	}
+	return
+}
+
+// landMany lands the flights for the missing keys with what the BulkValueMapper loaded
+func (u *unbounded) landMany(missing []interface{}, flights map[interface{}]*flight, loaded map[interface{}]interface{}, loadErr error, values map[interface{}]interface{}, failed KeyErrors) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, key := range missing {
+		value, keyErr := valueFromBulk(key, loaded, loadErr)
+		if value, keyErr = u.landLocked(key, flights[key], value, keyErr); keyErr != nil {
+			failed[key] = keyErr
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// valueFromBulk picks the result for a single key out of a BulkValueMapper's results
+func valueFromBulk(key interface{}, values map[interface{}]interface{}, err error) (value interface{}, keyErr error) {
+	if err != nil {
+		var keyErrs KeyErrors
+		if !errors.As(err, &keyErrs) {
+			return nil, err
+		}
+		if keyErr, ok := keyErrs[key]; ok {
+			return nil, keyErr
+		}
+	}
+	value, ok := values[key]
+	if !ok {
+		return nil, ErrNotLoaded
+	}
+	return value, nil
+}
+
+func valueMapperFromBulk(bulkMapper BulkValueMapper) ValueMapper {
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		values, err := bulkMapper(ctx, []interface{}{key})
+		return valueFromBulk(key, values, err)
+	}
+}
+
+func bulkMapperFromValue(valueMapper ValueMapper) BulkValueMapper {
+	return func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+		values = make(map[interface{}]interface{}, len(keys))
+		keyErrs := make(KeyErrors)
+		for _, key := range keys {
+			value, keyErr := valueMapper(ctx, key)
+			if keyErr != nil {
+				keyErrs[key] = keyErr
+				continue
+			}
+			values[key] = value
+		}
+		if len(keyErrs) != 0 {
+			err = keyErrs
+		}
+		return
+	}
+}
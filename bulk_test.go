@@ -0,0 +1,152 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"sync"
+)
+
+// recordingBulkMapper echoes keys back as values, except for those in failures, and records every call
+type recordingBulkMapper struct {
+	mu       sync.Mutex
+	calls    [][]interface{}
+	failures cache.KeyErrors
+}
+
+func (r *recordingBulkMapper) Load(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, keys)
+	values = make(map[interface{}]interface{})
+	keyErrs := make(cache.KeyErrors)
+	for _, key := range keys {
+		if failure, ok := r.failures[key]; ok {
+			if failure != nil {
+				keyErrs[key] = failure
+			}
+			continue
+		}
+		values[key] = key
+	}
+	if len(keyErrs) != 0 {
+		err = keyErrs
+	}
+	return
+}
+
+var _ = Describe("Bulk", func() {
+	var (
+		source  *recordingBulkMapper
+		subject cache.ManyGetInvalidater
+	)
+	BeforeEach(func() {
+		source = &recordingBulkMapper{
+			failures: make(cache.KeyErrors),
+		}
+	})
+
+	When("unbounded", func() {
+		BeforeEach(func() {
+			subject = cache.NewUnboundedBulk(source.Load)
+			_, _ = subject.Get(ignoreCtx, "1")
+		})
+		It("loads single keys in bulk", func() {
+			Expect(source.calls).Should(Equal([][]interface{}{{"1"}}))
+		})
+		It("only loads the misses", func() {
+			values, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2", "3"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(values).Should(Equal(map[interface{}]interface{}{"1": "1", "2": "2", "3": "3"}))
+			Expect(source.calls[1]).Should(Equal([]interface{}{"2", "3"}))
+		})
+		It("does not load when everything is cached", func() {
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"1", "1"})
+			Expect(source.calls).Should(HaveLen(1))
+		})
+		It("loads duplicates once", func() {
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"2", "2"})
+			Expect(source.calls[1]).Should(Equal([]interface{}{"2"}))
+		})
+	})
+
+	When("some keys fail", func() {
+		BeforeEach(func() {
+			subject = cache.NewUnboundedBulk(source.Load)
+			source.failures["bad"] = intentionalErr
+			source.failures["missing"] = nil
+		})
+		It("returns the rest", func() {
+			values, err := subject.GetMany(ignoreCtx, []interface{}{"good", "bad", "missing"})
+			Expect(values).Should(Equal(map[interface{}]interface{}{"good": "good"}))
+			Expect(err).Should(Equal(cache.KeyErrors{
+				"bad":     intentionalErr,
+				"missing": cache.ErrNotLoaded,
+			}))
+		})
+		It("does not cache the failures", func() {
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"good", "bad"})
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"good", "bad"})
+			Expect(source.calls[1]).Should(Equal([]interface{}{"bad"}))
+		})
+		It("fails single gets with the key's error", func() {
+			_, err := subject.Get(ignoreCtx, "bad")
+			Expect(err).Should(Equal(intentionalErr))
+			_, err = subject.Get(ignoreCtx, "missing")
+			Expect(err).Should(Equal(cache.ErrNotLoaded))
+		})
+	})
+
+	When("the whole load fails", func() {
+		BeforeEach(func() {
+			subject = cache.NewUnboundedBulk(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+				return nil, intentionalErr
+			})
+		})
+		It("fails every miss", func() {
+			values, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2"})
+			Expect(values).Should(BeEmpty())
+			Expect(err).Should(Equal(cache.KeyErrors{"1": intentionalErr, "2": intentionalErr}))
+		})
+	})
+
+	When("lru", func() {
+		BeforeEach(func() {
+			subject = cache.NewLRUBulk(2, func(value interface{}) uint {
+				return 1
+			}, source.Load)
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"1", "2"})
+		})
+		It("evicts to make room", func() {
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"2", "3"})
+			_, _ = subject.GetMany(ignoreCtx, []interface{}{"1"})
+			Expect(source.calls).Should(Equal([][]interface{}{{"1", "2"}, {"3"}, {"1"}}))
+			Expect(subject.(cache.Inspector).RecentKeys(10)).Should(Equal([]interface{}{"1", "3"}))
+		})
+		It("fails keys that do not fit", func() {
+			zero := cache.NewLRUBulk(0, func(value interface{}) uint {
+				return 1
+			}, source.Load)
+			_, err := zero.GetMany(ignoreCtx, []interface{}{"1"})
+			Expect(err).Should(Equal(cache.KeyErrors{"1": cache.ErrInsufficientCapacity}))
+		})
+	})
+
+	When("created with a ValueMapper", func() {
+		It("loads each miss", func() {
+			loads := 0
+			subject := cache.NewLRUItem(10, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				loads++
+				if key == "bad" {
+					return nil, intentionalErr
+				}
+				return key, nil
+			}).(cache.ManyGetter)
+			values, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2", "bad"})
+			Expect(values).Should(HaveLen(2))
+			Expect(err).Should(Equal(cache.KeyErrors{"bad": intentionalErr}))
+			Expect(loads).Should(Equal(3))
+		})
+	})
+})
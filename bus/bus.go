@@ -0,0 +1,231 @@
+package bus
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+// Transport carries encoded batches between the replicas sharing a Bus
+type Transport interface {
+	// Publish sends the payload to every subscriber, which may include this one
+	Publish(payload []byte) error
+
+	// Subscribe sets the function called with every payload received. It is called once, before anything is published
+	Subscribe(receive func(payload []byte))
+
+	// Close stops sending and receiving
+	Close() error
+}
+
+// Op is what to do to a cache
+type Op int
+
+const (
+	OpInvalidate Op = iota
+	OpInvalidateTag
+	OpClear
+)
+
+// Event is a single invalidation of a named cache. Key is the key for OpInvalidate, the tag for OpInvalidateTag,
+// and unused for OpClear
+type Event struct {
+	Cache string
+	Op    Op
+	Key   interface{}
+}
+
+// batch is what goes over the wire
+type batch struct {
+	Origin string
+	Events []Event
+}
+
+// Bus keeps the caches of many replicas in step. Invalidations made through the Bus are applied to the local cache
+// immediately, and published in batches to be applied to the caches registered under the same name on every other
+// replica. Invalidations received from other replicas are only applied, never published again, and each Bus
+// ignores its own batches, so messages never loop.
+//
+// Keys and tags are sent with encoding/gob. Strings and numbers just work, gob.Register any other types you use
+type Bus struct {
+	transport Transport
+	origin    string
+	window    time.Duration
+	maxBatch  int
+	onError   func(err error)
+
+	mu      sync.Mutex
+	caches  map[string]cache.Invalidater
+	pending []Event
+	timer   *time.Timer
+	closed  bool
+}
+
+// NewBus publishes over the transport.
+//
+// window: how long an invalidation waits for others to join its batch, zero to publish each one as it is made
+// maxBatch: batches are published as soon as they reach this many events, zero for no limit. Keep batches small
+// enough to fit in a single datagram for the multicast transport
+// onError: called with errors publishing or decoding batches, may be nil to ignore them
+func NewBus(transport Transport, window time.Duration, maxBatch int, onError func(err error)) *Bus {
+	if onError == nil {
+		onError = func(err error) {}
+	}
+	b := &Bus{
+		transport: transport,
+		origin:    newOrigin(),
+		window:    window,
+		maxBatch:  maxBatch,
+		onError:   onError,
+		caches:    make(map[string]cache.Invalidater),
+	}
+	transport.Subscribe(b.receive)
+	return b
+}
+
+func newOrigin() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Register applies the invalidations for name to the cache, replacing any cache already registered with that name.
+// Caches only need to be Invalidaters, tag invalidations and clears are ignored by caches that are not
+// cache.TagInvalidaters or cache.Clearers
+func (b *Bus) Register(name string, c cache.Invalidater) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.caches[name] = c
+}
+
+// Unregister stops applying invalidations for name. It does not change the cache itself
+func (b *Bus) Unregister(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.caches, name)
+}
+
+// Invalidate the key in the named cache, here and on every other replica
+func (b *Bus) Invalidate(name string, key interface{}) {
+	b.send(Event{Cache: name, Op: OpInvalidate, Key: key})
+}
+
+// InvalidateTag invalidates the tag in the named cache, here and on every other replica
+func (b *Bus) InvalidateTag(name string, tag interface{}) {
+	b.send(Event{Cache: name, Op: OpInvalidateTag, Key: tag})
+}
+
+// Clear the named cache, here and on every other replica
+func (b *Bus) Clear(name string) {
+	b.send(Event{Cache: name, Op: OpClear})
+}
+
+func (b *Bus) send(event Event) {
+	b.apply(event)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.pending = append(b.pending, event)
+	full := b.window <= 0 || (b.maxBatch > 0 && len(b.pending) >= b.maxBatch)
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(b.window, func() {
+			_ = b.Flush()
+		})
+	}
+	b.mu.Unlock()
+	if full {
+		_ = b.Flush()
+	}
+}
+
+// Flush publishes the pending invalidations now, instead of waiting for the window to end. Errors are also passed to
+// the Bus's onError
+func (b *Bus) Flush() error {
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	payload, err := encode(batch{Origin: b.origin, Events: events})
+	if err == nil {
+		err = b.transport.Publish(payload)
+	}
+	if err != nil {
+		b.onError(err)
+	}
+	return err
+}
+
+// Close publishes the pending invalidations and closes the transport. Invalidations made once Close is called are
+// only applied here
+func (b *Bus) Close() error {
+	// nothing is queued once closed, so the final flush publishes everything that was
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	flushErr := b.Flush()
+	if err := b.transport.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (b *Bus) receive(payload []byte) {
+	received, err := decode(payload)
+	if err != nil {
+		b.onError(err)
+		return
+	}
+	if received.Origin == b.origin {
+		return
+	}
+	for _, event := range received.Events {
+		b.apply(event)
+	}
+}
+
+// apply the event to the local cache
+func (b *Bus) apply(event Event) {
+	b.mu.Lock()
+	c, ok := b.caches[event.Cache]
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+	switch event.Op {
+	case OpInvalidate:
+		c.Invalidate(event.Key)
+	case OpInvalidateTag:
+		if tagged, ok := c.(cache.TagInvalidater); ok {
+			tagged.InvalidateTag(event.Key)
+		}
+	case OpClear:
+		if clearer, ok := c.(cache.Clearer); ok {
+			clearer.Clear()
+		}
+	}
+}
+
+func encode(b batch) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(b)
+	return buf.Bytes(), err
+}
+
+func decode(payload []byte) (b batch, err error) {
+	err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&b)
+	return
+}
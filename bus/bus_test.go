@@ -0,0 +1,271 @@
+package bus_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/bus"
+)
+
+// recordingCache records what was done to it
+type recordingCache struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (r *recordingCache) record(entry string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, entry)
+}
+
+func (r *recordingCache) Invalidate(key interface{}) {
+	r.record(fmt.Sprintf("invalidate %v", key))
+}
+
+func (r *recordingCache) InvalidateTag(tag interface{}) {
+	r.record(fmt.Sprintf("tag %v", tag))
+}
+
+func (r *recordingCache) Clear() {
+	r.record("clear")
+}
+
+func (r *recordingCache) Log() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.log...)
+}
+
+// countingTransport counts the batches published through it
+type countingTransport struct {
+	bus.Transport
+	mu        sync.Mutex
+	published int
+}
+
+func (c *countingTransport) Publish(payload []byte) error {
+	c.mu.Lock()
+	c.published++
+	c.mu.Unlock()
+	return c.Transport.Publish(payload)
+}
+
+func (c *countingTransport) Published() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.published
+}
+
+// blockingTransport holds each Publish until it is released
+type blockingTransport struct {
+	bus.Transport
+	publishing chan bool
+	release    chan bool
+}
+
+func (b *blockingTransport) Publish(payload []byte) error {
+	b.publishing <- true
+	<-b.release
+	return b.Transport.Publish(payload)
+}
+
+func newReplica(transport bus.Transport, window time.Duration, maxBatch int) (*bus.Bus, *recordingCache) {
+	b := bus.NewBus(transport, window, maxBatch, func(err error) {
+		defer GinkgoRecover()
+		Fail(err.Error())
+	})
+	c := &recordingCache{}
+	b.Register("users", c)
+	return b, c
+}
+
+var _ = Describe("Bus", func() {
+	var (
+		hub        *bus.LocalHub
+		transport  *countingTransport
+		local      *bus.Bus
+		localCache *recordingCache
+		remote     *bus.Bus
+		remoteLog  *recordingCache
+	)
+	BeforeEach(func() {
+		hub = bus.NewLocalHub()
+		transport = &countingTransport{Transport: hub.Transport()}
+		local, localCache = newReplica(transport, 0, 0)
+		remote, remoteLog = newReplica(hub.Transport(), 0, 0)
+	})
+	AfterEach(func() {
+		Expect(local.Close()).Should(Succeed())
+		Expect(remote.Close()).Should(Succeed())
+	})
+
+	It("applies invalidations here and on other replicas", func() {
+		local.Invalidate("users", 1)
+		local.InvalidateTag("users", "admins")
+		local.Clear("users")
+		expected := []string{"invalidate 1", "tag admins", "clear"}
+		Expect(localCache.Log()).Should(Equal(expected))
+		Expect(remoteLog.Log()).Should(Equal(expected))
+	})
+
+	It("does not apply received invalidations twice or send them back", func() {
+		remote.Invalidate("users", "a")
+		Expect(localCache.Log()).Should(Equal([]string{"invalidate a"}))
+		Expect(remoteLog.Log()).Should(Equal([]string{"invalidate a"}))
+		Expect(transport.Published()).Should(BeZero())
+	})
+
+	It("ignores caches that are not registered", func() {
+		local.Invalidate("orders", 1)
+		remote.Unregister("users")
+		local.Invalidate("users", 2)
+		Expect(remoteLog.Log()).Should(BeEmpty())
+		Expect(localCache.Log()).Should(Equal([]string{"invalidate 2"}))
+	})
+
+	It("skips operations the cache does not support", func() {
+		plain := cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return key, nil
+		})
+		_, _ = plain.Get(context.Background(), "a")
+		remote.Register("users", plain)
+		local.InvalidateTag("users", "admins")
+		local.Invalidate("users", "a")
+		Expect(plain.(cache.Inspector).Stats().Items).Should(BeZero())
+	})
+
+	It("batches invalidations made within the window", func() {
+		Expect(local.Close()).Should(Succeed())
+		transport = &countingTransport{Transport: hub.Transport()}
+		local, localCache = newReplica(transport, time.Hour, 3)
+		for i := 0; i < 7; i++ {
+			local.Invalidate("users", i)
+		}
+		Expect(transport.Published()).Should(Equal(2))
+		Expect(remoteLog.Log()).Should(HaveLen(6))
+		Expect(local.Flush()).Should(Succeed())
+		Expect(transport.Published()).Should(Equal(3))
+		Expect(remoteLog.Log()).Should(HaveLen(7))
+	})
+
+	It("publishes when the window ends", func() {
+		Expect(local.Close()).Should(Succeed())
+		local, localCache = newReplica(hub.Transport(), 10*time.Millisecond, 0)
+		local.Invalidate("users", 1)
+		local.Invalidate("users", 2)
+		Expect(remoteLog.Log()).Should(BeEmpty())
+		Eventually(remoteLog.Log).Should(Equal([]string{"invalidate 1", "invalidate 2"}))
+	})
+
+	It("publishes what is pending when closed", func() {
+		local.Invalidate("users", 1)
+		Expect(local.Close()).Should(Succeed())
+		local, localCache = newReplica(hub.Transport(), time.Hour, 0)
+		local.Invalidate("users", 2)
+		Expect(local.Close()).Should(Succeed())
+		Expect(remoteLog.Log()).Should(Equal([]string{"invalidate 1", "invalidate 2"}))
+		local, localCache = newReplica(hub.Transport(), 0, 0)
+	})
+
+	It("does not queue invalidations made while closing", func() {
+		Expect(local.Close()).Should(Succeed())
+		blocking := &blockingTransport{
+			Transport:  &countingTransport{Transport: hub.Transport()},
+			publishing: make(chan bool),
+			release:    make(chan bool),
+		}
+		local, localCache = newReplica(blocking, time.Hour, 0)
+		local.Invalidate("users", 1)
+		closed := make(chan error)
+		go func() {
+			closed <- local.Close()
+		}()
+		<-blocking.publishing
+		local.Invalidate("users", 2)
+		close(blocking.release)
+		Expect(<-closed).Should(Succeed())
+		Expect(local.Flush()).Should(Succeed())
+		Expect(blocking.Transport.(*countingTransport).Published()).Should(Equal(1))
+		Expect(localCache.Log()).Should(Equal([]string{"invalidate 1", "invalidate 2"}))
+		Expect(remoteLog.Log()).Should(Equal([]string{"invalidate 1"}))
+		local, localCache = newReplica(hub.Transport(), 0, 0)
+	})
+
+	Context("over a TCP hub", func() {
+		var (
+			server *bus.Hub
+		)
+		BeforeEach(func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ShouldNot(HaveOccurred())
+			server = bus.NewHub(listener)
+			Expect(local.Close()).Should(Succeed())
+			Expect(remote.Close()).Should(Succeed())
+			for _, replica := range []**bus.Bus{&local, &remote} {
+				transport, err := bus.DialHub(server.Addr().String())
+				Expect(err).ShouldNot(HaveOccurred())
+				*replica, _ = newReplica(transport, 0, 0)
+			}
+			local.Register("users", localCache)
+			remote.Register("users", remoteLog)
+		})
+		AfterEach(func() {
+			Expect(server.Close()).Should(Succeed())
+		})
+
+		It("relays invalidations between replicas", func() {
+			// wait for the hub to accept both subscribers
+			Eventually(func() []string {
+				local.Invalidate("users", "ping")
+				return remoteLog.Log()
+			}).ShouldNot(BeEmpty())
+			local.Invalidate("users", 1)
+			remote.Clear("users")
+			Eventually(remoteLog.Log).Should(ContainElement("invalidate 1"))
+			Eventually(localCache.Log).Should(ContainElement("clear"))
+			Consistently(func() (applied int) {
+				for _, entry := range localCache.Log() {
+					if entry == "invalidate 1" {
+						applied++
+					}
+				}
+				return
+			}, 20*time.Millisecond).Should(Equal(1))
+		})
+
+		It("disconnects subscribers that send malformed frames", func() {
+			conn, err := net.Dial("tcp", server.Addr().String())
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = conn.Write([]byte{0xff, 0xff, 0xff, 0xff})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = conn.Read(make([]byte, 1))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("over UDP multicast", func() {
+		It("delivers invalidations to the group", func() {
+			newTransport := func() bus.Transport {
+				transport, err := bus.NewMulticastTransport("239.77.77.77:47946", nil)
+				if err != nil {
+					Skip("multicast is not available: " + err.Error())
+				}
+				return transport
+			}
+			Expect(local.Close()).Should(Succeed())
+			Expect(remote.Close()).Should(Succeed())
+			local, _ = newReplica(newTransport(), 0, 0)
+			remote, _ = newReplica(newTransport(), 0, 0)
+			remote.Register("users", remoteLog)
+			local.Invalidate("users", 1)
+			Eventually(remoteLog.Log).Should(Equal([]string{"invalidate 1"}))
+		})
+	})
+})
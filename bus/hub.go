@@ -0,0 +1,216 @@
+package bus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+const (
+	// maxFrame is the largest batch the Hub will relay
+	maxFrame = 16 << 20
+
+	// hubQueue is how many batches may wait to be written to a subscriber before it is dropped as too slow
+	hubQueue = 256
+)
+
+var ErrFrameTooLarge = fmt.Errorf("frame is larger than %d bytes", maxFrame)
+
+// Hub is a TCP pub/sub server. Every batch a subscriber publishes is relayed to every subscriber, including the
+// one that sent it. Run one alongside your replicas and connect each replica's Bus to it with DialHub.
+//
+// Subscribers that fall too far behind are disconnected, as are those that send malformed frames
+type Hub struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	subscribers map[*hubSubscriber]bool
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+type hubSubscriber struct {
+	conn  net.Conn
+	queue chan []byte
+	once  sync.Once
+}
+
+// NewHub serves subscribers connecting to the listener until Close is called
+func NewHub(listener net.Listener) *Hub {
+	h := &Hub{
+		listener:    listener,
+		subscribers: make(map[*hubSubscriber]bool),
+	}
+	h.wg.Add(1)
+	go h.accept()
+	return h
+}
+
+// Addr is the address subscribers connect to
+func (h *Hub) Addr() net.Addr {
+	return h.listener.Addr()
+}
+
+// Close stops accepting subscribers and disconnects those already connected
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	for s := range h.subscribers {
+		h.dropLocked(s)
+	}
+	h.mu.Unlock()
+	err := h.listener.Close()
+	h.wg.Wait()
+	return err
+}
+
+func (h *Hub) accept() {
+	defer h.wg.Done()
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			return
+		}
+		s := &hubSubscriber{
+			conn:  conn,
+			queue: make(chan []byte, hubQueue),
+		}
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		h.subscribers[s] = true
+		h.mu.Unlock()
+		h.wg.Add(2)
+		go h.read(s)
+		go h.write(s)
+	}
+}
+
+// read relays every frame the subscriber sends
+func (h *Hub) read(s *hubSubscriber) {
+	defer h.wg.Done()
+	r := bufio.NewReader(s.conn)
+	for {
+		frame, err := readFrame(r)
+		if err != nil {
+			h.mu.Lock()
+			h.dropLocked(s)
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Lock()
+		for other := range h.subscribers {
+			select {
+			case other.queue <- frame:
+			default:
+				h.dropLocked(other)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) write(s *hubSubscriber) {
+	defer h.wg.Done()
+	w := bufio.NewWriter(s.conn)
+	for frame := range s.queue {
+		err := writeFrame(w, frame)
+		if err == nil && len(s.queue) == 0 {
+			err = w.Flush()
+		}
+		if err != nil {
+			h.mu.Lock()
+			h.dropLocked(s)
+			h.mu.Unlock()
+		}
+	}
+}
+
+// dropLocked disconnects the subscriber. Safe to call more than once
+func (h *Hub) dropLocked(s *hubSubscriber) {
+	s.once.Do(func() {
+		delete(h.subscribers, s)
+		close(s.queue)
+		_ = s.conn.Close()
+	})
+}
+
+type hubTransport struct {
+	conn net.Conn
+
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// DialHub connects to the Hub at addr. If the connection is lost, Publish fails and nothing more is received, so
+// create a new Bus with a new transport to reconnect
+func DialHub(addr string) (Transport, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &hubTransport{
+		conn: conn,
+		w:    bufio.NewWriter(conn),
+	}, nil
+}
+
+func (t *hubTransport) Publish(payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := writeFrame(t.w, payload); err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+func (t *hubTransport) Subscribe(receive func(payload []byte)) {
+	go func() {
+		r := bufio.NewReader(t.conn)
+		for {
+			frame, err := readFrame(r)
+			if err != nil {
+				return
+			}
+			receive(frame)
+		}
+	}()
+}
+
+func (t *hubTransport) Close() error {
+	return t.conn.Close()
+}
+
+// writeFrame writes the payload prefixed with its length
+func writeFrame(w io.Writer, payload []byte) error {
+	if len(payload) > maxFrame {
+		return ErrFrameTooLarge
+	}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(r io.Reader) (payload []byte, err error) {
+	var length [4]byte
+	if _, err = io.ReadFull(r, length[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxFrame {
+		return nil, ErrFrameTooLarge
+	}
+	payload = make([]byte, size)
+	_, err = io.ReadFull(r, payload)
+	return
+}
@@ -0,0 +1,65 @@
+package bus
+
+import (
+	"fmt"
+	"sync"
+)
+
+var ErrClosed = fmt.Errorf("transport is closed")
+
+// LocalHub fans batches out between Buses in the same process. Use it in tests, or to keep caches that are
+// deliberately kept separate, such as one per tenant, in step
+type LocalHub struct {
+	mu        sync.RWMutex
+	endpoints map[*localTransport]bool
+}
+
+func NewLocalHub() *LocalHub {
+	return &LocalHub{
+		endpoints: make(map[*localTransport]bool),
+	}
+}
+
+// Transport connects a new Bus to the hub
+func (h *LocalHub) Transport() Transport {
+	t := &localTransport{
+		hub: h,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endpoints[t] = true
+	return t
+}
+
+type localTransport struct {
+	hub     *LocalHub
+	receive func(payload []byte)
+}
+
+// Publish delivers the payload to every endpoint before returning
+func (t *localTransport) Publish(payload []byte) error {
+	t.hub.mu.RLock()
+	defer t.hub.mu.RUnlock()
+	if !t.hub.endpoints[t] {
+		return ErrClosed
+	}
+	for endpoint := range t.hub.endpoints {
+		if endpoint.receive != nil {
+			endpoint.receive(append([]byte(nil), payload...))
+		}
+	}
+	return nil
+}
+
+func (t *localTransport) Subscribe(receive func(payload []byte)) {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	t.receive = receive
+}
+
+func (t *localTransport) Close() error {
+	t.hub.mu.Lock()
+	defer t.hub.mu.Unlock()
+	delete(t.hub.endpoints, t)
+	return nil
+}
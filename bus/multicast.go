@@ -0,0 +1,66 @@
+package bus
+
+import (
+	"net"
+)
+
+// maxDatagram is the largest UDP payload
+const maxDatagram = 65507
+
+type multicastTransport struct {
+	listen *net.UDPConn
+	send   *net.UDPConn
+}
+
+// NewMulticastTransport sends each batch as a single UDP datagram to the multicast group, such as "239.1.2.3:7946",
+// and receives the batches every other replica sends to it. ifi picks the network interface to join the group on,
+// nil for the system default.
+//
+// Delivery is not guaranteed: datagrams may be lost, and batches larger than a datagram fail to publish. Use it for
+// invalidations that are also covered by a TTL or other bounded staleness, or use the TCP Hub
+func NewMulticastTransport(group string, ifi *net.Interface) (Transport, error) {
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, err
+	}
+	listen, err := net.ListenMulticastUDP("udp", ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+	_ = listen.SetReadBuffer(4 * maxDatagram)
+	send, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		_ = listen.Close()
+		return nil, err
+	}
+	return &multicastTransport{
+		listen: listen,
+		send:   send,
+	}, nil
+}
+
+func (t *multicastTransport) Publish(payload []byte) error {
+	_, err := t.send.Write(payload)
+	return err
+}
+
+func (t *multicastTransport) Subscribe(receive func(payload []byte)) {
+	go func() {
+		buf := make([]byte, maxDatagram)
+		for {
+			n, _, err := t.listen.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			receive(append([]byte(nil), buf[:n]...))
+		}
+	}()
+}
+
+func (t *multicastTransport) Close() error {
+	sendErr := t.send.Close()
+	if err := t.listen.Close(); err != nil {
+		return err
+	}
+	return sendErr
+}
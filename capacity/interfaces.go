@@ -5,6 +5,12 @@ type Tracker interface {
 	// IsLargerThanCapacity is true if the item will never fit into allotted capacity
 	// false if it could fit as-is or if other items were removed
 	IsLargerThanCapacity(itemSize uint) bool
+
+	// Len is the amount currently used
+	Len() uint
+
+	// Cap is the most that can be used
+	Cap() uint
 }
 
 type Mutator interface {
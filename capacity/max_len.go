@@ -34,3 +34,11 @@ func (m *maxLen) Remove(amount uint) {
 		m.len = m.len - amount
 	}
 }
+
+func (m *maxLen) Len() uint {
+	return m.len
+}
+
+func (m *maxLen) Cap() uint {
+	return m.cap
+}
@@ -60,6 +60,10 @@ var _ = Describe("MaxLen", func() {
 			It("can't fit items that are too large", func() {
 				Expect(subject.Add(4)).Should(BeFalse())
 			})
+			It("reports the length", func() {
+				Expect(subject.Len()).Should(Equal(startLen))
+				Expect(subject.Cap()).Should(Equal(max))
+			})
 			It("removes items", func() {
 				subject.Remove(1)
 				Expect(subject.Add(max - (startLen - 1))).Should(BeTrue())
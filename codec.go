@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ValueCodec converts cached values to bytes and back, for snapshots, peers and remote stores
+type ValueCodec interface {
+	Encode(value interface{}) (data []byte, err error)
+	Decode(data []byte) (value interface{}, err error)
+}
+
+type gobCodec struct{}
+
+// NewGobCodec encodes values with encoding/gob, keeping their types. gob.Register every type you cache, other than
+// the built in types such as strings, numbers and slices of them
+func NewGobCodec() ValueCodec {
+	return gobCodec{}
+}
+
+func (gobCodec) Encode(value interface{}) (data []byte, err error) {
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(&value)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Decode(data []byte) (value interface{}, err error) {
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return
+}
+
+type jsonCodec struct {
+	newValue func() interface{}
+}
+
+// NewJSONCodec encodes values with encoding/json. newValue returns a pointer to a new value of the type you cache,
+// such as func() interface{} { return new(User) }, which values are decoded into. The cache holds what the pointer
+// points to, not the pointer. newValue may be nil, in which case values are decoded as they would be into an
+// interface{}, as maps, slices, strings, float64s and bools
+func NewJSONCodec(newValue func() interface{}) ValueCodec {
+	return jsonCodec{
+		newValue: newValue,
+	}
+}
+
+func (j jsonCodec) Encode(value interface{}) (data []byte, err error) {
+	return json.Marshal(value)
+}
+
+func (j jsonCodec) Decode(data []byte) (value interface{}, err error) {
+	if j.newValue == nil {
+		err = json.Unmarshal(data, &value)
+		return
+	}
+	decoded := j.newValue()
+	if err = json.Unmarshal(data, decoded); err != nil {
+		return
+	}
+	return reflect.ValueOf(decoded).Elem().Interface(), nil
+}
+
+type bytesCodec struct{}
+
+// NewBytesCodec stores byte slices as they are, use it to snapshot NewLRUByte caches
+func NewBytesCodec() ValueCodec {
+	return bytesCodec{}
+}
+
+func (bytesCodec) Encode(value interface{}) (data []byte, err error) {
+	data, ok := value.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("bytes codec cannot encode a %T", value)
+	}
+	return data, nil
+}
+
+func (bytesCodec) Decode(data []byte) (value interface{}, err error) {
+	return data, nil
+}
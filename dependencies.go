@@ -0,0 +1,55 @@
+package cache
+
+// WithDependencies declares that a loaded value was computed from the values of other keys, so invalidating any of
+// them also invalidates it, along with anything that depends on it in turn. Return it from your ValueMapper, or as a
+// value from your BulkValueMapper, in place of the value itself. It may be combined with WithTags and WithNamespace.
+//
+// The dependencies need not be cached themselves, and may even depend back on the value. They are only followed when
+// keys are invalidated, directly or by tag, prefix or predicate, not when they are evicted or go stale after an
+// InvalidateAll or InvalidateNamespace. The value's dependencies are forgotten once it leaves the cache
+func WithDependencies(value interface{}, keys ...interface{}) interface{} {
+	a := annotate(value)
+	a.dependsOn = append(append([]interface{}{}, a.dependsOn...), keys...)
+	return a
+}
+
+// invalidateLocked grounds and removes the key and, transitively, every key that depends on it
+func (u *unbounded) invalidateLocked(key interface{}) {
+	u.clock++
+	if len(u.dependencies.keysByTag) == 0 {
+		u.invalidateOneLocked(key)
+		return
+	}
+	visited := map[interface{}]bool{key: true}
+	pending := []interface{}{key}
+	for len(pending) != 0 {
+		key := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, dependent := range u.dependencies.keys(key) {
+			if !visited[dependent] {
+				visited[dependent] = true
+				pending = append(pending, dependent)
+			}
+		}
+		u.invalidateOneLocked(key)
+	}
+}
+
+func (u *unbounded) invalidateOneLocked(key interface{}) {
+	if len(u.inflight) != 0 {
+		// loads in progress that depend on the key must not cache what they computed from its old value
+		u.invalidations[key] = u.clock
+	}
+	u.groundLocked(key)
+	u.removeLocked(key)
+}
+
+// dependencyInvalidatedLocked is true if any of the keys were invalidated after loadedAt
+func (u *unbounded) dependencyInvalidatedLocked(loadedAt uint64, dependsOn []interface{}) bool {
+	for _, key := range dependsOn {
+		if u.invalidations[key] > loadedAt {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+)
+
+var _ = Describe("Dependencies", func() {
+	var (
+		dependsOn map[string][]interface{}
+		subject   cache.GetInvalidater
+		load      func(keys ...string)
+	)
+	// dependentMapper echoes the key, depending on the keys in dependsOn
+	dependentMapper := func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		return cache.WithDependencies(key, dependsOn[key.(string)]...), nil
+	}
+	BeforeEach(func() {
+		dependsOn = map[string][]interface{}{
+			"page":     {"template", "data"},
+			"template": {"partial"},
+		}
+		subject = cache.NewLRUItem(5, dependentMapper)
+		load = func(keys ...string) {
+			for _, key := range keys {
+				_, _ = subject.Get(ignoreCtx, key)
+			}
+		}
+		load("partial", "data", "template", "page")
+	})
+	sortedKeys := func() []string {
+		return sortedCachedKeys(subject)
+	}
+
+	It("invalidates dependents", func() {
+		subject.Invalidate("data")
+		Expect(sortedKeys()).Should(Equal([]string{"partial", "template"}))
+	})
+
+	It("invalidates dependents transitively", func() {
+		subject.Invalidate("partial")
+		Expect(sortedKeys()).Should(Equal([]string{"data"}))
+	})
+
+	It("leaves dependencies of invalidated keys alone", func() {
+		subject.Invalidate("page")
+		Expect(sortedKeys()).Should(Equal([]string{"data", "partial", "template"}))
+	})
+
+	It("invalidates dependents of keys that are not cached", func() {
+		subject.Invalidate("partial")
+		load("template", "page")
+		subject.Invalidate("partial")
+		Expect(sortedKeys()).Should(Equal([]string{"data"}))
+	})
+
+	It("cascades tag, prefix and predicate invalidation", func() {
+		dependsOn["tagged"] = []interface{}{"page"}
+		subject = cache.NewLRUItem(5, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			value, _ = dependentMapper(ctx, key)
+			return cache.WithTags(value, key), nil
+		})
+		load("data", "page", "tagged")
+		subject.(cache.TagInvalidater).InvalidateTag("data")
+		Expect(sortedKeys()).Should(BeEmpty())
+
+		load("data", "page", "tagged")
+		subject.(cache.PrefixInvalidater).InvalidatePrefix("dat")
+		Expect(sortedKeys()).Should(BeEmpty())
+
+		load("data", "page", "tagged")
+		subject.(cache.PredicateInvalidater).InvalidateWhere(func(key interface{}) bool {
+			return key == "data"
+		})
+		Expect(sortedKeys()).Should(BeEmpty())
+	})
+
+	It("survives cycles", func() {
+		dependsOn = map[string][]interface{}{
+			"a": {"b"},
+			"b": {"c"},
+			"c": {"a", "c"},
+		}
+		subject.(cache.Clearer).Clear()
+		load("a", "b", "c", "data")
+		subject.Invalidate("b")
+		Expect(sortedKeys()).Should(Equal([]string{"data"}))
+	})
+
+	It("does not cascade evictions", func() {
+		load("other1", "other2")
+		Expect(sortedKeys()).Should(Equal([]string{"data", "other1", "other2", "page", "template"}))
+	})
+
+	It("forgets the dependencies of evicted keys", func() {
+		load("other1", "other2", "other3", "other4", "other5")
+		delete(dependsOn, "page")
+		load("page")
+		subject.Invalidate("data")
+		Expect(sortedKeys()).Should(ContainElement("page"))
+	})
+
+	It("does not cache loads whose dependencies were invalidated while loading", func() {
+		started, release := make(chan bool, 1), make(chan bool)
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			if key == "page" {
+				started <- true
+				<-release
+			}
+			return dependentMapper(ctx, key)
+		})
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			Expect(subject.Get(ignoreCtx, "page")).Should(Equal("page"))
+		}()
+		<-started
+		subject.Invalidate("data")
+		close(release)
+		<-done
+		Expect(sortedKeys()).Should(BeEmpty())
+		load("page")
+		Expect(sortedKeys()).Should(Equal([]string{"page"}))
+	})
+	When("loads are always in progress", func() {
+		var (
+			started chan bool
+			mu      sync.Mutex
+			gates   map[interface{}]chan bool
+		)
+		// start loading the key, returning a channel that releases the load and one closed once it lands
+		start := func(key string) (release, done chan bool) {
+			release, done = make(chan bool), make(chan bool)
+			mu.Lock()
+			gates[key] = release
+			mu.Unlock()
+			go func() {
+				defer close(done)
+				_, _ = subject.Get(ignoreCtx, key)
+			}()
+			<-started
+			return
+		}
+		BeforeEach(func() {
+			started = make(chan bool)
+			gates = make(map[interface{}]chan bool)
+			subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				mu.Lock()
+				release := gates[key]
+				mu.Unlock()
+				started <- true
+				<-release
+				return dependentMapper(ctx, key)
+			})
+		})
+
+		It("forgets invalidations once no load in progress started before them", func() {
+			release, done := start("0")
+			for i := 1; i < 1000; i++ {
+				nextRelease, nextDone := start(strconv.Itoa(i))
+				subject.Invalidate("other" + strconv.Itoa(i))
+				close(release)
+				<-done
+				release, done = nextRelease, nextDone
+			}
+			Expect(cache.InvalidationsPending(subject)).Should(BeNumerically("<", 100))
+			close(release)
+			<-done
+			Expect(cache.InvalidationsPending(subject)).Should(BeZero())
+		})
+
+		It("keeps the invalidations loads still in progress depend on", func() {
+			releaseEarly, earlyDone := start("early")
+			for i := 0; i < 100; i++ {
+				subject.Invalidate("other" + strconv.Itoa(i))
+			}
+			release, done := start("page")
+			subject.Invalidate("data")
+			close(releaseEarly)
+			<-earlyDone
+			Expect(cache.InvalidationsPending(subject)).Should(Equal(1))
+			close(release)
+			<-done
+			Expect(sortedKeys()).Should(Equal([]string{"early"}))
+		})
+	})
+})
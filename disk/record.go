@@ -0,0 +1,81 @@
+package disk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// headerSize is the checksum, flags, key length and value length that start every record
+const headerSize = 4 + 1 + 4 + 4
+
+// maxRecordBody is the most a key and value may add up to. Larger lengths can only come from corruption
+const maxRecordBody = 1 << 30
+
+const (
+	flagPut       byte = 0
+	flagTombstone byte = 1
+)
+
+var ErrCorrupt = fmt.Errorf("record failed its checksum")
+
+// record is a put or delete of a key, as written to a segment:
+//
+//	crc32 | flags | key length | value length | key | value
+//
+// The checksum covers everything after itself
+type record struct {
+	flags byte
+	key   string
+	value []byte
+}
+
+func (r record) size() int64 {
+	return int64(headerSize + len(r.key) + len(r.value))
+}
+
+func (r record) encode() []byte {
+	buf := make([]byte, r.size())
+	buf[4] = r.flags
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(r.key)))
+	binary.BigEndian.PutUint32(buf[9:13], uint32(len(r.value)))
+	copy(buf[headerSize:], r.key)
+	copy(buf[headerSize+len(r.key):], r.value)
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[4:]))
+	return buf
+}
+
+// readRecord reads the record starting at offset. Reading at the end of the segment is io.EOF, while records cut short
+// by a crash are io.ErrUnexpectedEOF
+func readRecord(r io.ReaderAt, offset int64) (rec record, err error) {
+	var header [headerSize]byte
+	if n, readErr := r.ReadAt(header[:], offset); n < headerSize {
+		if n > 0 && readErr == io.EOF {
+			readErr = io.ErrUnexpectedEOF
+		}
+		return rec, readErr
+	}
+	keyLen := binary.BigEndian.Uint32(header[5:9])
+	valueLen := binary.BigEndian.Uint32(header[9:13])
+	if uint64(keyLen)+uint64(valueLen) > maxRecordBody {
+		return rec, ErrCorrupt
+	}
+	body := make([]byte, int(keyLen)+int(valueLen))
+	if n, readErr := r.ReadAt(body, offset+headerSize); n < len(body) {
+		if readErr == io.EOF {
+			readErr = io.ErrUnexpectedEOF
+		}
+		return rec, readErr
+	}
+	checksum := crc32.NewIEEE()
+	_, _ = checksum.Write(header[4:])
+	_, _ = checksum.Write(body)
+	if checksum.Sum32() != binary.BigEndian.Uint32(header[0:4]) {
+		return rec, ErrCorrupt
+	}
+	rec.flags = header[4]
+	rec.key = string(body[:keyLen])
+	rec.value = body[keyLen:]
+	return
+}
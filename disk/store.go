@@ -0,0 +1,427 @@
+package disk
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wojnosystems/go-cache/capacity"
+	"github.com/wojnosystems/go-cache/lru"
+)
+
+const segmentSuffix = ".seg"
+
+var (
+	ErrTooLarge = fmt.Errorf("value is larger than the store's capacity")
+	ErrClosed   = fmt.Errorf("store is closed")
+)
+
+// location is where a key's latest record is
+type location struct {
+	segment  uint64
+	offset   int64
+	size     int64
+	valueLen uint
+}
+
+// Store keeps byte values in append-only segment files, with an in-memory index of where each key's value is.
+// Every record carries a checksum, values that fail it are dropped instead of returned. When it is full, the least
+// recently used values are evicted, and once more of the files are taken up by replaced, deleted and evicted
+// values than live ones, the live values are compacted into new segments.
+//
+// A Store is a cache, not a database. Writes are not synced, so a crash may lose the most recent of them, and a
+// segment whose end was damaged is truncated when the Store is opened again
+type Store struct {
+	dir          string
+	segmentBytes int64
+
+	mu         sync.Mutex
+	segments   map[uint64]*os.File
+	active     uint64
+	activeSize int64
+	index      map[string]location
+	tracker    lru.Tracker
+	limit      capacity.TrackMutator
+
+	// live and garbage are how many bytes of the segments hold the latest values, and everything else
+	live    int64
+	garbage int64
+	closed  bool
+}
+
+// Open the store in dir, creating it if it does not exist, and index the values already in it.
+//
+// maxBytes: the most value bytes the store will hold. The files take up more than this, with the keys, record
+// headers and up to as much again in replaced values waiting to be compacted
+// segmentBytes: segment files are started anew once they reach this size
+func Open(dir string, maxBytes uint, segmentBytes uint) (s *Store, err error) {
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	s = &Store{
+		dir:          dir,
+		segmentBytes: int64(segmentBytes),
+		segments:     make(map[uint64]*os.File),
+		index:        make(map[string]location),
+		tracker:      lru.NewTracker(),
+		limit:        capacity.NewMaxLen(maxBytes),
+	}
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	var evicted []string
+	for _, id := range ids {
+		if evicted, err = s.replay(id, evicted); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		err = s.startSegmentLocked(1)
+	} else {
+		s.active = ids[len(ids)-1]
+		var info os.FileInfo
+		if info, err = s.segments[s.active].Stat(); err == nil {
+			s.activeSize = info.Size()
+		}
+	}
+	for _, key := range evicted {
+		if _, reput := s.index[key]; !reput && err == nil {
+			err = s.tombstoneLocked(key)
+		}
+	}
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) segmentIDs() (ids []uint64, err error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, parseErr := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if parseErr == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return
+}
+
+func (s *Store) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", id, segmentSuffix))
+}
+
+// replay indexes the records in the segment, truncating it at the first record that is damaged. Keys evicted to
+// make room are added to evicted, so they can be deleted once the store is open
+func (s *Store) replay(id uint64, evicted []string) ([]string, error) {
+	file, err := os.OpenFile(s.segmentPath(id), os.O_RDWR, 0)
+	if err != nil {
+		return evicted, err
+	}
+	s.segments[id] = file
+	var offset int64
+	for {
+		rec, err := readRecord(file, offset)
+		if err == io.EOF {
+			return evicted, nil
+		}
+		if err == io.ErrUnexpectedEOF || err == ErrCorrupt {
+			return evicted, file.Truncate(offset)
+		}
+		if err != nil {
+			return evicted, err
+		}
+		s.removeLocked(rec.key)
+		if rec.flags == flagTombstone {
+			s.garbage += rec.size()
+		} else {
+			if s.limit.IsLargerThanCapacity(uint(len(rec.value))) {
+				s.garbage += rec.size()
+			} else {
+				for !s.limit.Add(uint(len(rec.value))) {
+					evicted = append(evicted, s.evictLocked())
+				}
+				s.indexLocked(rec.key, location{
+					segment:  id,
+					offset:   offset,
+					size:     rec.size(),
+					valueLen: uint(len(rec.value)),
+				})
+			}
+		}
+		offset += rec.size()
+	}
+}
+
+// Get the value for key, ok is false if the store does not have it, or it was damaged
+func (s *Store) Get(key string) (value []byte, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.index[key]
+	if !ok || s.closed {
+		return nil, false
+	}
+	rec, err := readRecord(s.segments[loc.segment], loc.offset)
+	if err != nil || rec.key != key {
+		s.removeLocked(key)
+		_ = s.tombstoneLocked(key)
+		return nil, false
+	}
+	s.tracker.Touch(key)
+	return rec.value, true
+}
+
+// Put stores the value for key, evicting the least recently used values to make room for it
+func (s *Store) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	valueLen := uint(len(value))
+	if s.limit.IsLargerThanCapacity(valueLen) {
+		return ErrTooLarge
+	}
+	// the value is written before anything is removed, so a failed write leaves the store as it was, on disk too
+	rec := record{flags: flagPut, key: key, value: value}
+	loc, err := s.appendLocked(rec)
+	if err != nil {
+		return err
+	}
+	loc.valueLen = valueLen
+	s.removeLocked(key)
+	var evicted []string
+	for !s.limit.Add(valueLen) {
+		evicted = append(evicted, s.evictLocked())
+	}
+	s.indexLocked(key, loc)
+	for _, evictedKey := range evicted {
+		if err = s.tombstoneLocked(evictedKey); err != nil {
+			return err
+		}
+	}
+	return s.maybeCompactLocked()
+}
+
+// Delete the value for key, if the store has it
+func (s *Store) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.index[key]; !ok || s.closed {
+		return
+	}
+	s.removeLocked(key)
+	_ = s.tombstoneLocked(key)
+}
+
+// Keys returns every key the store has, in no particular order
+func (s *Store) Keys() (keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys = make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	return
+}
+
+// Usage is how many value bytes are stored, out of the most the store may hold
+func (s *Store) Usage() (used uint, capacity uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit.Len(), s.limit.Cap()
+}
+
+// Clear deletes every value, along with the segment files holding them
+func (s *Store) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	for key := range s.index {
+		s.removeLocked(key)
+	}
+	next := s.active + 1
+	if err := s.removeSegmentsLocked(); err != nil {
+		return err
+	}
+	s.live, s.garbage = 0, 0
+	return s.startSegmentLocked(next)
+}
+
+// Compact rewrites the live values into new segments and deletes the old ones. It happens on its own as values are
+// replaced, so it only needs to be called to reclaim the space right away
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.compactLocked()
+}
+
+// Close the segment files. The store can be opened again from the same dir
+func (s *Store) Close() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for id, file := range s.segments {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(s.segments, id)
+	}
+	return
+}
+
+func (s *Store) indexLocked(key string, loc location) {
+	s.index[key] = loc
+	s.tracker.Touch(key)
+	s.live += loc.size
+}
+
+// removeLocked forgets the key, its record becomes garbage
+func (s *Store) removeLocked(key string) {
+	loc, ok := s.index[key]
+	if !ok {
+		return
+	}
+	delete(s.index, key)
+	s.tracker.Remove(key)
+	s.limit.Remove(loc.valueLen)
+	s.live -= loc.size
+	s.garbage += loc.size
+}
+
+// evictLocked removes the least recently used key and returns it
+func (s *Store) evictLocked() string {
+	key, _ := s.tracker.LRU()
+	s.removeLocked(key.(string))
+	return key.(string)
+}
+
+// tombstoneLocked records that the key was removed, so it is not indexed again when the store is reopened
+func (s *Store) tombstoneLocked(key string) error {
+	rec := record{flags: flagTombstone, key: key}
+	if _, err := s.appendLocked(rec); err != nil {
+		return err
+	}
+	s.garbage += rec.size()
+	return nil
+}
+
+// appendLocked writes the record to the end of the active segment, starting a new one if it is full
+func (s *Store) appendLocked(rec record) (loc location, err error) {
+	if s.activeSize > 0 && s.activeSize+rec.size() > s.segmentBytes {
+		if err = s.startSegmentLocked(s.active + 1); err != nil {
+			return
+		}
+	}
+	if _, err = s.segments[s.active].WriteAt(rec.encode(), s.activeSize); err != nil {
+		return
+	}
+	loc = location{
+		segment: s.active,
+		offset:  s.activeSize,
+		size:    rec.size(),
+	}
+	s.activeSize += rec.size()
+	return
+}
+
+func (s *Store) startSegmentLocked(id uint64) error {
+	file, err := os.OpenFile(s.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments[id] = file
+	s.active = id
+	s.activeSize = 0
+	return nil
+}
+
+func (s *Store) removeSegmentsLocked() error {
+	for id, file := range s.segments {
+		_ = file.Close()
+		delete(s.segments, id)
+		if err := os.Remove(s.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// maybeCompactLocked compacts once at least a segment's worth of the files is garbage, and more of them is garbage
+// than live values
+func (s *Store) maybeCompactLocked() error {
+	if s.garbage < s.segmentBytes || s.garbage <= s.live {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+// compactLocked copies the live values to new segments, least recently used first, so reopening the store
+// restores their order, then deletes the old segments. Values that fail their checksum are dropped
+func (s *Store) compactLocked() error {
+	var keys []string
+	s.tracker.Walk(func(key interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	old := s.segments
+	s.segments = make(map[uint64]*os.File)
+	if err := s.startSegmentLocked(s.active + 1); err != nil {
+		s.segments = old
+		return err
+	}
+	index := make(map[string]location, len(keys))
+	var live int64
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		from := s.index[key]
+		rec, err := readRecord(old[from.segment], from.offset)
+		if err != nil || rec.key != key {
+			s.removeLocked(key)
+			continue
+		}
+		to, err := s.appendLocked(rec)
+		if err != nil {
+			// leave the store as it was, the new segments become garbage
+			for id, file := range s.segments {
+				old[id] = file
+			}
+			s.segments = old
+			return err
+		}
+		to.valueLen = from.valueLen
+		index[key] = to
+		live += to.size
+	}
+	s.index = index
+	s.live, s.garbage = live, 0
+	for id, file := range old {
+		_ = file.Close()
+		if err := os.Remove(s.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
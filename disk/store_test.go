@@ -0,0 +1,204 @@
+package disk_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/disk"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir     string
+		subject *disk.Store
+		open    func(maxBytes, segmentBytes uint)
+	)
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "disk-store")
+		Expect(err).ShouldNot(HaveOccurred())
+		open = func(maxBytes, segmentBytes uint) {
+			if subject != nil {
+				Expect(subject.Close()).Should(Succeed())
+			}
+			subject, err = disk.Open(dir, maxBytes, segmentBytes)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		subject = nil
+		open(10, 64)
+	})
+	AfterEach(func() {
+		Expect(subject.Close()).Should(Succeed())
+		Expect(os.RemoveAll(dir)).Should(Succeed())
+	})
+	get := func(key string) interface{} {
+		value, ok := subject.Get(key)
+		if !ok {
+			return nil
+		}
+		return string(value)
+	}
+	sortedKeys := func() []string {
+		keys := subject.Keys()
+		sort.Strings(keys)
+		return keys
+	}
+	segmentSizes := func() (total int64) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, file := range files {
+			info, err := os.Stat(file)
+			Expect(err).ShouldNot(HaveOccurred())
+			total += info.Size()
+		}
+		return
+	}
+
+	It("stores values", func() {
+		Expect(subject.Put("a", []byte("123"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("45"))).Should(Succeed())
+		Expect(subject.Put("a", []byte("6"))).Should(Succeed())
+		Expect(get("a")).Should(Equal("6"))
+		Expect(get("b")).Should(Equal("45"))
+		Expect(get("c")).Should(BeNil())
+		used, capacity := subject.Usage()
+		Expect(used).Should(Equal(uint(3)))
+		Expect(capacity).Should(Equal(uint(10)))
+	})
+
+	It("evicts the least recently used values to stay within capacity", func() {
+		Expect(subject.Put("a", []byte("1234"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("1234"))).Should(Succeed())
+		get("a")
+		Expect(subject.Put("c", []byte("1234"))).Should(Succeed())
+		Expect(sortedKeys()).Should(Equal([]string{"a", "c"}))
+	})
+
+	It("refuses values larger than its capacity", func() {
+		Expect(subject.Put("a", make([]byte, 11))).Should(MatchError(disk.ErrTooLarge))
+	})
+
+	It("deletes values", func() {
+		Expect(subject.Put("a", []byte("1"))).Should(Succeed())
+		subject.Delete("a")
+		subject.Delete("missing")
+		Expect(get("a")).Should(BeNil())
+	})
+
+	It("indexes the values already on disk when opened", func() {
+		Expect(subject.Put("a", []byte("1"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("2"))).Should(Succeed())
+		Expect(subject.Put("c", []byte("3"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("22"))).Should(Succeed())
+		subject.Delete("c")
+		open(10, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"a", "b"}))
+		Expect(get("b")).Should(Equal("22"))
+	})
+
+	It("keeps the most recently written values that fit when opened with less capacity", func() {
+		for _, key := range []string{"a", "b", "c", "d"} {
+			Expect(subject.Put(key, []byte("12"))).Should(Succeed())
+		}
+		open(4, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"c", "d"}))
+		open(10, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"c", "d"}))
+	})
+
+	It("drops values that fail their checksum", func() {
+		Expect(subject.Put("a", []byte("hello"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("world"))).Should(Succeed())
+		files, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		file, err := os.OpenFile(files[0], os.O_RDWR, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = file.WriteAt([]byte("J"), 14)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(file.Close()).Should(Succeed())
+		Expect(get("a")).Should(BeNil())
+		Expect(get("b")).Should(Equal("world"))
+		Expect(sortedKeys()).Should(Equal([]string{"b"}))
+	})
+
+	It("truncates segments damaged by a crash when opened", func() {
+		Expect(subject.Put("a", []byte("hello"))).Should(Succeed())
+		Expect(subject.Put("b", []byte("world"))).Should(Succeed())
+		Expect(subject.Close()).Should(Succeed())
+		files, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		info, err := os.Stat(files[0])
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(os.Truncate(files[0], info.Size()-2)).Should(Succeed())
+		subject, err = disk.Open(dir, 10, 64)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sortedKeys()).Should(Equal([]string{"a"}))
+		Expect(subject.Put("c", []byte("again"))).Should(Succeed())
+		open(10, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"a", "c"}))
+	})
+
+	It("keeps the old value when writing the new one fails", func() {
+		open(10, 1)
+		Expect(subject.Put("a", []byte("1"))).Should(Succeed())
+		files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+		Expect(err).ShouldNot(HaveOccurred())
+		sort.Strings(files)
+		var last uint64
+		_, err = fmt.Sscanf(filepath.Base(files[len(files)-1]), "%d", &last)
+		Expect(err).ShouldNot(HaveOccurred())
+		// a directory where the next segment goes can't be opened as one
+		next := filepath.Join(dir, fmt.Sprintf("%016d.seg", last+1))
+		Expect(os.Mkdir(next, 0o755)).Should(Succeed())
+		Expect(subject.Put("a", []byte("2"))).ShouldNot(Succeed())
+		Expect(get("a")).Should(Equal("1"))
+		Expect(os.Remove(next)).Should(Succeed())
+		open(10, 1)
+		Expect(get("a")).Should(Equal("1"))
+	})
+
+	It("starts new segments as they fill up", func() {
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			Expect(subject.Put(key, []byte("12"))).Should(Succeed())
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+		Expect(len(files)).Should(BeNumerically(">", 1))
+		open(10, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"a", "b", "c", "d", "e"}))
+	})
+
+	It("compacts replaced values away", func() {
+		for i := 0; i < 100; i++ {
+			Expect(subject.Put("a", []byte("1234"))).Should(Succeed())
+			Expect(subject.Put("b", []byte("5678"))).Should(Succeed())
+		}
+		Expect(segmentSizes()).Should(BeNumerically("<", 3*64))
+		Expect(subject.Compact()).Should(Succeed())
+		Expect(segmentSizes()).Should(Equal(int64(2 * (13 + 1 + 4))))
+		Expect(get("a")).Should(Equal("1234"))
+		open(10, 64)
+		Expect(get("b")).Should(Equal("5678"))
+	})
+
+	It("keeps the recency order through compaction", func() {
+		for _, key := range []string{"a", "b", "c"} {
+			Expect(subject.Put(key, []byte("12"))).Should(Succeed())
+		}
+		get("a")
+		Expect(subject.Compact()).Should(Succeed())
+		open(4, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"a", "c"}))
+	})
+
+	It("clears everything", func() {
+		Expect(subject.Put("a", []byte("1"))).Should(Succeed())
+		Expect(subject.Clear()).Should(Succeed())
+		Expect(subject.Keys()).Should(BeEmpty())
+		Expect(segmentSizes()).Should(BeZero())
+		Expect(subject.Put("b", []byte("2"))).Should(Succeed())
+		open(10, 64)
+		Expect(sortedKeys()).Should(Equal([]string{"b"}))
+	})
+})
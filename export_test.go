@@ -0,0 +1,9 @@
+package cache
+
+// InvalidationsPending is how many invalidations the unbounded cache remembers for the loads in progress
+func InvalidationsPending(c GetInvalidater) int {
+	u := c.(*unbounded)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.invalidations) + len(u.tagInvalidations)
+}
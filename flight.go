@@ -0,0 +1,129 @@
+package cache
+
+import "context"
+
+// flight is a load in progress. Callers that miss on a key that is already being loaded wait for
+// the same flight instead of loading it again
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// invalidated flights still answer their waiters, but their value is not cached
+	invalidated bool
+
+	// loadedAt is the clock when the flight took off
+	loadedAt uint64
+}
+
+func newFlight() *flight {
+	return &flight{
+		done: make(chan struct{}),
+	}
+}
+
+// wait for the flight to land, or give up if ctx is done first
+func (f *flight) wait(ctx context.Context) (value interface{}, err error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// takeOffLocked registers a new flight for the key, or returns the one already in progress
+func (u *unbounded) takeOffLocked(key interface{}) (f *flight, isNew bool) {
+	if existing, ok := u.inflight[key]; ok {
+		return existing, false
+	}
+	f = newFlight()
+	f.loadedAt = u.clock
+	u.inflight[key] = f
+	return f, true
+}
+
+// landLocked caches the loaded value, unless the load failed or was invalidated, then releases the waiters.
+// The value may be annotated by the loader, the bare value is returned
+func (u *unbounded) landLocked(key interface{}, f *flight, value interface{}, err error) (interface{}, error) {
+	value, notes := unannotate(value)
+	if u.inflight[key] == f {
+		delete(u.inflight, key)
+	}
+	if err != nil {
+		u.stats.LoadErrors++
+	} else if !f.invalidated {
+		err = u.keepLocked(key, notes, f.loadedAt)
+	}
+	u.forgetInvalidationsLocked()
+	if err != nil {
+		value = nil
+	}
+	f.value, f.err = value, err
+	close(f.done)
+	return value, err
+}
+
+// keepLocked caches the annotated value for a load that started at loadedAt, unless it is already stale
+func (u *unbounded) keepLocked(key interface{}, notes *annotated, loadedAt uint64) error {
+	s := u.stampLocked(loadedAt, notes)
+	if u.stale(s) || u.dependencyInvalidatedLocked(loadedAt, notes.dependsOn) || u.tagInvalidatedLocked(loadedAt, notes.tags) {
+		return nil
+	}
+	if err := u.safeStoreLocked(key, notes.value, s); err != nil {
+		return err
+	}
+	u.tags.add(key, notes.tags)
+	u.dependencies.add(key, notes.dependsOn)
+	return nil
+}
+
+// minInvalidationsPruned is how many invalidations may pile up before they are pruned
+const minInvalidationsPruned = 64
+
+// forgetInvalidationsLocked drops the invalidations of keys and tags that no load in progress started before.
+// They are all dropped once no loads are in progress, and otherwise pruned whenever they double, so they don't pile up
+// while there always are some
+func (u *unbounded) forgetInvalidationsLocked() {
+	if len(u.inflight) == 0 {
+		if len(u.invalidations) != 0 {
+			u.invalidations = make(map[interface{}]uint64)
+		}
+		if len(u.tagInvalidations) != 0 {
+			u.tagInvalidations = make(map[interface{}]uint64)
+		}
+		u.invalidationsKept = 0
+		return
+	}
+	if len(u.invalidations)+len(u.tagInvalidations) < 2*u.invalidationsKept+minInvalidationsPruned {
+		return
+	}
+	oldest := u.clock
+	for _, f := range u.inflight {
+		if f.loadedAt < oldest {
+			oldest = f.loadedAt
+		}
+	}
+	for _, invalidations := range []map[interface{}]uint64{u.invalidations, u.tagInvalidations} {
+		for key, at := range invalidations {
+			if at <= oldest {
+				delete(invalidations, key)
+			}
+		}
+	}
+	u.invalidationsKept = len(u.invalidations) + len(u.tagInvalidations)
+}
+
+// groundLocked stops the flight's value from being cached and lets new callers start a fresh load
+func (u *unbounded) groundLocked(key interface{}) {
+	if f, ok := u.inflight[key]; ok {
+		f.invalidated = true
+		delete(u.inflight, key)
+	}
+}
+
+// safeStoreLocked is storeLocked, but a panic from a ValueSizer or other hook is returned as a LoaderPanicError
+func (u *unbounded) safeStoreLocked(key, value interface{}, s stamp) (err error) {
+	defer recoverLoaderPanic(&err)
+	return u.storeLocked(key, value, s)
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// GenerationInvalidater invalidates everything, or everything in a namespace, without visiting the cached keys.
+// Entries loaded before the call count as misses from then on, and are removed when they are next looked up,
+// evicted as they go unused, or the cache is next inspected. Caches that don't evict, such as NewUnbounded, keep the
+// memory of entries that are never looked up again until then
+type GenerationInvalidater interface {
+	// InvalidateAll invalidates every cached and loading key
+	InvalidateAll()
+
+	// InvalidateNamespace invalidates every cached and loading key whose value was put in the namespace with
+	// WithNamespace
+	InvalidateNamespace(namespace interface{})
+}
+
+// WithNamespace puts a loaded value in a namespace, so it can be invalidated along with everything else in the
+// namespace with InvalidateNamespace. Return it from your ValueMapper, or as a value from your BulkValueMapper,
+// in place of the value itself. It may be combined with WithTags. Callers of Get receive the value, without the
+// namespace
+func WithNamespace(value interface{}, namespace interface{}) interface{} {
+	a := annotate(value)
+	a.namespace = namespace
+	return a
+}
+
+// stamp records when an entry was loaded, so entries loaded before an InvalidateAll or InvalidateNamespace
+// can be told apart from those loaded after it, and when it expires. The zero stamp is for entries without a
+// namespace or TTL, loaded before either InvalidateAll or InvalidateNamespace was ever called
+type stamp struct {
+	// loadedAt is the clock when the load started
+	loadedAt uint64
+
+	// invalidatedAt points to the clock when the entry's namespace was last invalidated, nil for no namespace
+	invalidatedAt *uint64
+	namespace     interface{}
+
+	// expiresAt is in Unix nanoseconds, zero to never expire
+	expiresAt int64
+}
+
+// stale is true if the entry expired or was loaded before it was invalidated. Safe to call without holding mu
+func (u *unbounded) stale(s stamp) bool {
+	if s.expiresAt != 0 && time.Now().UnixNano() >= s.expiresAt {
+		return true
+	}
+	if s.loadedAt < atomic.LoadUint64(&u.invalidatedAt) {
+		return true
+	}
+	return s.invalidatedAt != nil && s.loadedAt < atomic.LoadUint64(s.invalidatedAt)
+}
+
+// stampLocked stamps a load of the annotated value that started at loadedAt
+func (u *unbounded) stampLocked(loadedAt uint64, notes *annotated) (s stamp) {
+	s.loadedAt = loadedAt
+	s.expiresAt = notes.expiresAt
+	if notes.namespace != nil {
+		s.namespace = notes.namespace
+		s.invalidatedAt = u.namespaceLocked(notes.namespace)
+	}
+	return
+}
+
+// namespaceLocked returns the clock when the namespace was last invalidated
+func (u *unbounded) namespaceLocked(namespace interface{}) *uint64 {
+	invalidatedAt, ok := u.namespaces[namespace]
+	if !ok {
+		invalidatedAt = new(uint64)
+		u.namespaces[namespace] = invalidatedAt
+	}
+	return invalidatedAt
+}
+
+// lookupLocked returns the cached value for the key, removing it instead if it is stale
+func (u *unbounded) lookupLocked(key interface{}) (value interface{}, ok bool) {
+	if value, ok = u.cache[key]; ok && u.stale(u.stamps[key]) {
+		u.removeLocked(key)
+		return nil, false
+	}
+	return
+}
+
+func (u *unbounded) InvalidateAll() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.invalidateAllLocked()
+}
+
+func (u *unbounded) invalidateAllLocked() {
+	u.clock++
+	u.generationAt = u.clock
+	atomic.StoreUint64(&u.invalidatedAt, u.clock)
+}
+
+func (u *unbounded) InvalidateNamespace(namespace interface{}) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.clock++
+	u.generationAt = u.clock
+	atomic.StoreUint64(u.namespaceLocked(namespace), u.clock)
+}
+
+// sweepLocked removes the entries InvalidateAll and InvalidateNamespace made stale since the last sweep, so Stats,
+// Usage and RecentKeys don't count them
+func (u *unbounded) sweepLocked() {
+	if u.sweptAt >= u.generationAt {
+		return
+	}
+	u.sweptAt = u.clock
+	for key := range u.cache {
+		if u.stale(u.stamps[key]) {
+			u.removeLocked(key)
+		}
+	}
+}
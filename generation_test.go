@@ -0,0 +1,148 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strings"
+	"sync/atomic"
+)
+
+var _ = Describe("Generations", func() {
+	var (
+		loads    *int32
+		subject  cache.GetInvalidater
+		loadedAs func(key string) interface{}
+	)
+	// namespacedMapper echoes the key, in the namespace before the first ':'
+	namespacedMapper := func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		atomic.AddInt32(loads, 1)
+		parts := strings.SplitN(key.(string), ":", 2)
+		if len(parts) == 1 {
+			return key, nil
+		}
+		return cache.WithTags(cache.WithNamespace(key, parts[0]), "tagged"), nil
+	}
+	BeforeEach(func() {
+		loads = new(int32)
+		subject = cache.NewLRUItem(10, namespacedMapper)
+		loadedAs = func(key string) interface{} {
+			value, err := subject.Get(ignoreCtx, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			return value
+		}
+		for _, key := range []string{"a:1", "a:2", "b:1", "plain"} {
+			loadedAs(key)
+		}
+	})
+
+	It("reloads everything after InvalidateAll", func() {
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		for _, key := range []string{"a:1", "a:2", "b:1", "plain"} {
+			Expect(loadedAs(key)).Should(Equal(key))
+		}
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(8)))
+	})
+
+	It("caches what is loaded after InvalidateAll", func() {
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		loadedAs("plain")
+		loadedAs("plain")
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(5)))
+	})
+
+	It("reloads only the namespace after InvalidateNamespace", func() {
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		for _, key := range []string{"a:1", "a:2", "b:1", "plain"} {
+			loadedAs(key)
+		}
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(6)))
+		Expect(subject.(cache.Inspector).Stats().Hits).Should(Equal(uint64(2)))
+	})
+
+	It("ignores namespaces nothing is in", func() {
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("c")
+		loadedAs("a:1")
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(4)))
+	})
+
+	It("reloads stale entries with GetMany", func() {
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		Expect(subject.(cache.ManyGetter).GetMany(ignoreCtx, []interface{}{"a:1", "b:1"})).Should(HaveLen(2))
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(5)))
+		Expect(cachedKeys(subject)).Should(ConsistOf("a:1", "b:1", "plain"))
+	})
+
+	It("does not count stale entries when inspected", func() {
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		inspector := subject.(cache.Inspector)
+		Expect(inspector.Stats().Items).Should(Equal(2))
+		used, _ := inspector.Usage()
+		Expect(used).Should(Equal(uint(2)))
+		Expect(cachedKeys(subject)).Should(ConsistOf("b:1", "plain"))
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		Expect(inspector.Stats().Items).Should(BeZero())
+	})
+
+	It("removes stale entries from unbounded caches when inspected", func() {
+		subject = cache.NewUnbounded(namespacedMapper)
+		for _, key := range []string{"a:1", "a:2", "b:1", "plain"} {
+			loadedAs(key)
+		}
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		inspector := subject.(cache.Inspector)
+		used, _ := inspector.Usage()
+		Expect(used).Should(Equal(uint(2)))
+		Expect(inspector.Stats().Items).Should(Equal(2))
+		Expect(inspector.RecentKeys(10)).Should(ConsistOf("b:1", "plain"))
+	})
+
+	It("forgets the tags of stale entries", func() {
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		loadedAs("a:1")
+		subject.(cache.TagInvalidater).InvalidateTag("tagged")
+		loadedAs("a:1")
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(6)))
+	})
+
+	It("does not cache loads that started before the invalidation", func() {
+		started, release := make(chan bool), make(chan bool)
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			started <- true
+			<-release
+			return namespacedMapper(ctx, key)
+		})
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			Expect(subject.Get(ignoreCtx, "a:slow")).Should(Equal("a:slow"))
+		}()
+		<-started
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		close(release)
+		<-done
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+	})
+
+	It("invalidates buffered LRU hits", func() {
+		subject = cache.NewBufferedLRU(10, itemSizer, namespacedMapper)
+		loadedAs("a:1")
+		loadedAs("a:1")
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		loadedAs("a:1")
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(6)))
+	})
+
+	It("invalidates every shard", func() {
+		subject = cache.NewShardedLRU(4, 100, itemSizer, namespacedMapper, nil)
+		for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+			loadedAs(key)
+		}
+		subject.(cache.GenerationInvalidater).InvalidateNamespace("a")
+		for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+			loadedAs(key)
+		}
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(4 + 4 + 3)))
+	})
+})
@@ -0,0 +1,30 @@
+package hashring
+
+// Picker chooses which member owns a key
+type Picker interface {
+	// Get the member that owns key, ok is false if there are no members
+	Get(key string) (member string, ok bool)
+}
+
+// Hash is FNV-1a followed by the splitmix64 finalizer, so keys that differ by a character still spread evenly
+func Hash(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return mix64(hash)
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,13 @@
+package hashring_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestHashring(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Hashring Suite")
+}
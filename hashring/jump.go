@@ -0,0 +1,15 @@
+package hashring
+
+// Jump is Lamping and Veach's jump consistent hash. It maps the key to one of buckets numbered 0 to buckets-1, without
+// any memory, and spreads keys more evenly than a Ring. Going from n to n+1 buckets only moves 1/(n+1) of the keys,
+// all of them to the new bucket. Buckets can only be added or removed at the end, so use it for shards, or replicas
+// numbered in order, rather than members that come and go. buckets must be at least 1
+func Jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
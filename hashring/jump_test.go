@@ -0,0 +1,38 @@
+package hashring_test
+
+import (
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/hashring"
+)
+
+// buckets returns the bucket of each of keyCount keys, as a member name so the ring helpers can be reused
+func buckets(n int) []string {
+	owned := make([]string, keyCount)
+	for i := range owned {
+		owned[i] = strconv.Itoa(hashring.Jump(hashring.Hash("key"+strconv.Itoa(i)), n))
+	}
+	return owned
+}
+
+var _ = Describe("Jump", func() {
+	It("spreads the keys evenly", func() {
+		count := counts(buckets(10))
+		Expect(count).Should(HaveLen(10))
+		for bucket, owned := range count {
+			Expect(strconv.Atoi(bucket)).Should(BeNumerically("<", 10))
+			Expect(owned).Should(BeNumerically("~", keyCount/10, keyCount/10/20))
+		}
+	})
+
+	It("only moves keys to a bucket that is added", func() {
+		moved := expectMovedOnlyTo(buckets(10), buckets(11), "10")
+		Expect(moved).Should(BeNumerically("~", 1.0/11, 0.01))
+	})
+
+	It("puts everything in one bucket", func() {
+		Expect(counts(buckets(1))).Should(Equal(map[string]int{"0": keyCount}))
+	})
+})
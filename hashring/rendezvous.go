@@ -0,0 +1,66 @@
+package hashring
+
+import (
+	"math"
+	"sync"
+)
+
+// Rendezvous is highest random weight hashing. Each key is scored against every member, and belongs to the member
+// with the highest score. Removing a member only moves its own keys, and adding one only moves the keys it now wins.
+// It needs no virtual nodes, but lookups take time in proportion to the number of members, so it suits tens of
+// members rather than thousands. Safe for concurrent use
+type Rendezvous struct {
+	mu      sync.RWMutex
+	weights map[string]float64
+}
+
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{
+		weights: make(map[string]float64),
+	}
+}
+
+// Add the member, or change its weight if it is already a member. A member with a weight of 2 owns about twice the
+// keys of one with a weight of 1
+func (r *Rendezvous) Add(member string, weight float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.weights[member] = weight
+}
+
+// Remove the member, only its keys move
+func (r *Rendezvous) Remove(member string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.weights, member)
+}
+
+func (r *Rendezvous) Get(key string) (member string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best := math.Inf(-1)
+	for candidate, weight := range r.weights {
+		score := r.score(key, candidate, weight)
+		if score > best || (score == best && candidate < member) {
+			best, member, ok = score, candidate, true
+		}
+	}
+	return
+}
+
+// score is the logarithmic method for weighted rendezvous hashing: -weight / ln(h), with h the hash of the key and
+// member scaled to (0, 1)
+func (r *Rendezvous) score(key, member string, weight float64) float64 {
+	h := (float64(Hash(key+"\x00"+member)>>11) + 0.5) / (1 << 53)
+	return -weight / math.Log(h)
+}
+
+// Members returns every member, in no particular order
+func (r *Rendezvous) Members() (members []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for member := range r.weights {
+		members = append(members, member)
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package hashring_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/hashring"
+)
+
+var _ = Describe("Rendezvous", func() {
+	var subject *hashring.Rendezvous
+	BeforeEach(func() {
+		subject = hashring.NewRendezvous()
+		for i := 0; i < 10; i++ {
+			subject.Add(member(i), 1)
+		}
+	})
+
+	It("has no owner when empty", func() {
+		_, ok := hashring.NewRendezvous().Get("key")
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("spreads the keys evenly", func() {
+		count := counts(owners(subject))
+		Expect(count).Should(HaveLen(10))
+		for _, owned := range count {
+			Expect(owned).Should(BeNumerically("~", keyCount/10, keyCount/10/20))
+		}
+	})
+
+	It("gives members keys in proportion to their weight", func() {
+		subject.Add(member(0), 3)
+		count := counts(owners(subject))
+		others := (keyCount - count[member(0)]) / 9
+		Expect(float64(count[member(0)]) / float64(others)).Should(BeNumerically("~", 3, 0.3))
+	})
+
+	It("only moves keys to a member that is added", func() {
+		before := owners(subject)
+		subject.Add(member(10), 1)
+		moved := expectMovedOnlyTo(before, owners(subject), member(10))
+		Expect(moved).Should(BeNumerically("~", 1.0/11, 0.01))
+	})
+
+	It("only moves the keys of a member that is removed", func() {
+		before := owners(subject)
+		subject.Remove(member(3))
+		moved := expectMovedOnlyFrom(before, owners(subject), member(3))
+		Expect(moved).Should(BeNumerically("~", 1.0/10, 0.01))
+	})
+})
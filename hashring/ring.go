@@ -0,0 +1,103 @@
+package hashring
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// point is one of a member's virtual nodes
+type point struct {
+	hash   uint64
+	member string
+}
+
+// Ring is a consistent hash ring. Each member is placed at many points, its virtual nodes, and a key belongs to the
+// member at the first point at or after the key's hash. Adding or removing a member only moves the keys between its
+// points and the ones before them, about 1/n of the keys for n members. Safe for concurrent use
+type Ring struct {
+	replicas int
+
+	mu      sync.RWMutex
+	points  []point
+	weights map[string]int
+}
+
+// New creates an empty ring.
+//
+// replicas: how many virtual nodes a member with a weight of 1 has. More spread the keys more evenly, at the cost of
+// memory and slower changes. 100 to 200 is typical
+func New(replicas int) *Ring {
+	return &Ring{
+		replicas: replicas,
+		weights:  make(map[string]int),
+	}
+}
+
+// Add the member, or change its weight if it is already a member. A member with a weight of 2 has twice the
+// virtual nodes of one with a weight of 1, so it owns about twice the keys
+func (r *Ring) Add(member string, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeLocked(member)
+	r.weights[member] = weight
+	for i := 0; i < r.replicas*weight; i++ {
+		r.points = append(r.points, point{
+			hash:   Hash(member + "#" + strconv.Itoa(i)),
+			member: member,
+		})
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].member < r.points[j].member
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+}
+
+// Remove the member, its keys move to the members after each of its points
+func (r *Ring) Remove(member string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeLocked(member)
+}
+
+func (r *Ring) removeLocked(member string) {
+	if _, ok := r.weights[member]; !ok {
+		return
+	}
+	delete(r.weights, member)
+	kept := r.points[:0]
+	for _, p := range r.points {
+		if p.member != member {
+			kept = append(kept, p)
+		}
+	}
+	r.points = kept
+}
+
+func (r *Ring) Get(key string) (member string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return "", false
+	}
+	hash := Hash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].member, true
+}
+
+// Members returns every member, in no particular order
+func (r *Ring) Members() (members []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for member := range r.weights {
+		members = append(members, member)
+	}
+	return
+}
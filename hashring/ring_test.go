@@ -0,0 +1,104 @@
+package hashring_test
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/hashring"
+)
+
+const keyCount = 100000
+
+// owners returns the member owning each of keyCount keys
+func owners(picker hashring.Picker) []string {
+	owned := make([]string, keyCount)
+	for i := range owned {
+		owned[i], _ = picker.Get("key" + strconv.Itoa(i))
+	}
+	return owned
+}
+
+// counts is how many keys each member owns
+func counts(owned []string) map[string]int {
+	count := make(map[string]int)
+	for _, member := range owned {
+		count[member]++
+	}
+	return count
+}
+
+// expectMovedOnlyTo expects every key that changed owner to have moved to member, and returns the fraction that moved
+func expectMovedOnlyTo(before, after []string, member string) float64 {
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			Expect(after[i]).Should(Equal(member))
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(before))
+}
+
+// expectMovedOnlyFrom expects every key that changed owner to have been owned by member, and returns the fraction
+// that moved
+func expectMovedOnlyFrom(before, after []string, member string) float64 {
+	moved := 0
+	for i := range before {
+		if before[i] != after[i] {
+			Expect(before[i]).Should(Equal(member))
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(before))
+}
+
+func member(i int) string {
+	return fmt.Sprintf("10.0.0.%d:8080", i)
+}
+
+var _ = Describe("Ring", func() {
+	var subject *hashring.Ring
+	BeforeEach(func() {
+		subject = hashring.New(200)
+		for i := 0; i < 10; i++ {
+			subject.Add(member(i), 1)
+		}
+	})
+
+	It("has no owner when empty", func() {
+		_, ok := hashring.New(200).Get("key")
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("spreads the keys evenly", func() {
+		count := counts(owners(subject))
+		Expect(count).Should(HaveLen(10))
+		for _, owned := range count {
+			Expect(owned).Should(BeNumerically("~", keyCount/10, keyCount/10/4))
+		}
+	})
+
+	It("gives members keys in proportion to their weight", func() {
+		subject.Add(member(0), 3)
+		count := counts(owners(subject))
+		others := (keyCount - count[member(0)]) / 9
+		Expect(float64(count[member(0)]) / float64(others)).Should(BeNumerically("~", 3, 0.6))
+	})
+
+	It("only moves keys to a member that is added", func() {
+		before := owners(subject)
+		subject.Add(member(10), 1)
+		moved := expectMovedOnlyTo(before, owners(subject), member(10))
+		Expect(moved).Should(BeNumerically("~", 1.0/11, 0.03))
+	})
+
+	It("only moves the keys of a member that is removed", func() {
+		before := owners(subject)
+		subject.Remove(member(3))
+		moved := expectMovedOnlyFrom(before, owners(subject), member(3))
+		Expect(moved).Should(BeNumerically("~", 1.0/10, 0.03))
+		Expect(subject.Members()).Should(HaveLen(9))
+	})
+})
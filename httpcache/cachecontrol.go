@@ -0,0 +1,151 @@
+package httpcache
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl is the directives of a Cache-Control header, with lowercase names. Directives without a value, such as
+// no-store, map to ""
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, line := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(line, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, value = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// seconds is the directive's value as a duration, ok is false if it is missing or not a number of seconds
+func (cc cacheControl) seconds(directive string) (d time.Duration, ok bool) {
+	value, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// ageOf is the response's Age header, zero if it has none
+func ageOf(header http.Header) time.Duration {
+	age, err := strconv.ParseInt(header.Get("Age"), 10, 64)
+	if err != nil || age < 0 {
+		return 0
+	}
+	return time.Duration(age) * time.Second
+}
+
+// varyNames are the canonical request header names the response varies on, sorted. ok is false for "Vary: *",
+// which can never be matched
+func varyNames(header http.Header) (names []string, ok bool) {
+	for _, line := range header.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// sharedFreshness is how long a shared cache may serve the response without asking the origin again. ok is false
+// if a shared cache must not store it at all
+func sharedFreshness(req *http.Request, status int, header http.Header) (fresh time.Duration, ok bool) {
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || cc.has("private") || cc.has("no-cache") || status == http.StatusPartialContent {
+		return 0, false
+	}
+	if _, ok = varyNames(header); !ok || len(header.Values("Set-Cookie")) > 0 {
+		return 0, false
+	}
+	if parseCacheControl(req.Header).has("no-store") {
+		return 0, false
+	}
+	// responses to requests with credentials are private unless they say otherwise
+	if req.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return 0, false
+	}
+	if fresh, ok = cc.seconds("s-maxage"); !ok {
+		if fresh, ok = cc.seconds("max-age"); !ok {
+			return 0, false
+		}
+	}
+	fresh -= ageOf(header)
+	return fresh, fresh > 0
+}
+
+// privateFreshness is when the response stops being fresh in a private cache, which received it at responseTime for
+// a request sent at requestTime. ok is false if it must not be stored. Responses without an explicit lifetime, from
+// Cache-Control's max-age or Expires, are stored only if they have a validator, and are revalidated before every use,
+// as are those marked no-cache
+func privateFreshness(status int, header http.Header, requestTime, responseTime time.Time) (expiresAt time.Time, ok bool) {
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || !storableStatus(status) {
+		return time.Time{}, false
+	}
+	if _, ok = varyNames(header); !ok {
+		return time.Time{}, false
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = responseTime
+	}
+	lifetime, explicit := cc.seconds("max-age")
+	if expires := header.Get("Expires"); !explicit && expires != "" {
+		// an Expires that can't be parsed is in the past
+		explicit = true
+		if at, err := http.ParseTime(expires); err == nil {
+			lifetime = at.Sub(date)
+		}
+	}
+	if !explicit && header.Get("ETag") == "" && header.Get("Last-Modified") == "" {
+		return time.Time{}, false
+	}
+	if cc.has("no-cache") {
+		lifetime = 0
+	}
+	age := ageOf(header)
+	if apparent := responseTime.Sub(date); apparent > age {
+		age = apparent
+	}
+	age += responseTime.Sub(requestTime)
+	return responseTime.Add(lifetime - age), true
+}
+
+// storableStatus is true for the statuses a cache may store responses with
+func storableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
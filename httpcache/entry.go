@@ -0,0 +1,152 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry is a response as it is cached
+type entry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// StoredAt and ExpiresAt are Unix nanoseconds
+	StoredAt  int64
+	ExpiresAt int64
+
+	// Vary is the values of the request headers named in the response's Vary header, for the request it answered
+	Vary map[string][]string
+}
+
+// newEntry is the response to req as it would be cached, taking the values of the request headers it varies on
+func newEntry(req *http.Request, status int, header http.Header, body []byte, storedAt, expiresAt time.Time) *entry {
+	names, _ := varyNames(header)
+	vary := make(map[string][]string, len(names))
+	for _, name := range names {
+		vary[name] = req.Header.Values(name)
+	}
+	return &entry{
+		Status:    status,
+		Header:    header,
+		Body:      body,
+		StoredAt:  storedAt.UnixNano(),
+		ExpiresAt: expiresAt.UnixNano(),
+		Vary:      vary,
+	}
+}
+
+func (e *entry) encode() (data []byte, err error) {
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(e)
+	return buf.Bytes(), err
+}
+
+func decodeEntry(data []byte) (e *entry, err error) {
+	e = &entry{}
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(e)
+	return
+}
+
+// fresh is true until the entry expires
+func (e *entry) fresh(now time.Time) bool {
+	return now.UnixNano() < e.ExpiresAt
+}
+
+// matches is true if the request has the same values for the Vary headers as the one the entry answered
+func (e *entry) matches(req *http.Request) bool {
+	for name, values := range e.Vary {
+		if strings.Join(values, ", ") != strings.Join(req.Header.Values(name), ", ") {
+			return false
+		}
+	}
+	return true
+}
+
+// age is how old the response is, counting any age it had when it was stored
+func (e *entry) age(now time.Time) time.Duration {
+	return ageOf(e.Header) + time.Duration(now.UnixNano()-e.StoredAt)
+}
+
+// setAge sets the Age header to how old the response is
+func (e *entry) setAge(now time.Time) {
+	e.Header.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+}
+
+// writeTo writes the response. HEAD requests get the headers without the body
+func (e *entry) writeTo(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	for name, values := range e.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	w.WriteHeader(e.Status)
+	if req.Method != http.MethodHead {
+		_, _ = w.Write(e.Body)
+	}
+}
+
+// response is the entry as a response to the request, with its Age. HEAD requests get the headers without the body
+func (e *entry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.age(time.Now())/time.Second), 10))
+	body, length := e.Body, int64(len(e.Body))
+	if req.Method == http.MethodHead {
+		body, length = nil, -1
+		if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+			length = n
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: length,
+		Request:       req,
+	}
+}
+
+// recorder captures a handler's response, so it can be cached
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// entry is the recorded response, as it would be cached
+func (r *recorder) entry(req *http.Request, storedAt, expiresAt time.Time) *entry {
+	return newEntry(req, r.status, r.header.Clone(), r.body.Bytes(), storedAt, expiresAt)
+}
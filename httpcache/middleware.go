@@ -0,0 +1,140 @@
+package httpcache
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+// Handler is middleware caching the responses of the handler it wraps, as a shared cache would
+type Handler struct {
+	next  http.Handler
+	store cache.ByteGetInvalidator
+}
+
+// NewHandler caches next's responses to GET and HEAD requests, keyed on the method, host, URL and the request
+// headers named in the response's Vary header.
+//
+// Only responses with an explicit lifetime are cached, from Cache-Control's s-maxage, or max-age if it has none.
+// Responses marked no-store, private or no-cache, varying on *, setting cookies, or answering requests with an
+// Authorization header unless they are marked public, are passed through without being cached, as are requests
+// marked no-store. Responses served from the cache carry an Age header. Concurrent misses for the same key run next
+// once, and share its response if it may be cached.
+//
+// Successful POST, PUT, PATCH and DELETE requests invalidate what is cached for their URL, if the store is a
+// cache.PrefixInvalidater. Responses are buffered in full, so don't wrap handlers that stream
+func NewHandler(next http.Handler, newStore StoreFactory) *Handler {
+	return &Handler{
+		next:  next,
+		store: newStore(load),
+	}
+}
+
+// target is the URL the request is for, without its scheme, which the handler can't know
+func target(req *http.Request) string {
+	return req.Host + req.URL.RequestURI()
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		h.serveCached(w, req)
+	case unsafe(req.Method):
+		sw := &statusWriter{ResponseWriter: w}
+		h.next.ServeHTTP(sw, req)
+		if sw.status < 400 {
+			h.Invalidate(target(req))
+		}
+	default:
+		h.next.ServeHTTP(w, req)
+	}
+}
+
+func (h *Handler) serveCached(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	base := keyBase(req.Method, target(req))
+	names := lookupVaryNames(ctx, h.store, base)
+	for attempt := 0; attempt < 2; attempt++ {
+		key := responseKey(base, names, req)
+		var (
+			recorded  *entry
+			cacheable bool
+			ran       bool
+		)
+		data, err := getOrLoad(ctx, h.store, key, func() ([]byte, error) {
+			ran = true
+			recorded, cacheable = h.run(req)
+			// a response varying on headers other than the ones it was looked up by is kept once the names are known
+			if !cacheable || !sameNames(names, recorded.Vary) {
+				return nil, errUncacheable
+			}
+			return recorded.encode()
+		})
+		if ran {
+			if recorded == nil {
+				if panicErr, ok := err.(*cache.LoaderPanicError); ok {
+					panic(panicErr.Value)
+				}
+				return
+			}
+			if cacheable && err == errUncacheable {
+				keepVariant(ctx, h.store, base, req, recorded)
+			}
+			recorded.writeTo(w, req)
+			return
+		}
+		if err != nil {
+			break
+		}
+		e, err := decodeEntry(data)
+		if err != nil || !e.fresh(time.Now()) {
+			h.store.Invalidate(key)
+			continue
+		}
+		if !e.matches(req) {
+			break
+		}
+		e.setAge(time.Now())
+		e.writeTo(w, req)
+		return
+	}
+	// the response loaded for another request could not be shared with this one
+	recorded, _ := h.run(req)
+	recorded.writeTo(w, req)
+}
+
+// run the wrapped handler, returning its response and whether it may be cached
+func (h *Handler) run(req *http.Request) (recorded *entry, cacheable bool) {
+	rec := newRecorder()
+	h.next.ServeHTTP(rec, req)
+	now := time.Now()
+	fresh, cacheable := sharedFreshness(req, rec.status, rec.header)
+	return rec.entry(req, now, now.Add(fresh)), cacheable
+}
+
+// Invalidate everything cached for the URL, given as the host and request URI, such as "example.com/users?page=2",
+// for every method and variant. It needs the store to be a cache.PrefixInvalidater
+func (h *Handler) Invalidate(url string) {
+	invalidateTarget(h.store, url)
+}
+
+// statusWriter remembers the status written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
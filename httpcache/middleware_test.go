@@ -0,0 +1,206 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/httpcache"
+)
+
+func newLRUStore(valueMapper cache.ByteMapper) cache.ByteGetInvalidator {
+	return cache.NewLRUByte(1<<20, valueMapper)
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		runs         int32
+		status       int
+		cacheControl string
+		extraHeaders http.Header
+		subject      *httpcache.Handler
+	)
+	BeforeEach(func() {
+		runs = 0
+		status = http.StatusTeapot
+		cacheControl = "max-age=60"
+		extraHeaders = http.Header{}
+		subject = httpcache.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&runs, 1)
+			w.Header().Set("Cache-Control", cacheControl)
+			for name, values := range extraHeaders {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(status)
+			_, _ = fmt.Fprintf(w, "run %d for %s", n, r.Header.Get("Accept-Encoding"))
+		}), newLRUStore)
+	})
+	serve := func(method, url string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		subject.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("caches responses with a max-age", func() {
+		first := serve(http.MethodGet, "/a")
+		Expect(first.Code).Should(Equal(http.StatusTeapot))
+		Expect(first.Header().Get("Age")).Should(BeEmpty())
+		second := serve(http.MethodGet, "/a")
+		Expect(second.Code).Should(Equal(http.StatusTeapot))
+		Expect(second.Body.String()).Should(Equal("run 1 for "))
+		Expect(second.Header().Get("Cache-Control")).Should(Equal("max-age=60"))
+		Expect(second.Header().Get("Age")).Should(Equal("0"))
+		serve(http.MethodGet, "/a?page=2")
+		Expect(runs).Should(Equal(int32(2)))
+	})
+
+	It("does not count looking up the headers responses vary on as load errors", func() {
+		var store cache.ByteGetInvalidator
+		subject = httpcache.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", cacheControl)
+			_, _ = fmt.Fprint(w, "body")
+		}), func(valueMapper cache.ByteMapper) cache.ByteGetInvalidator {
+			store = newLRUStore(valueMapper)
+			return store
+		})
+		serve(http.MethodGet, "/a")
+		serve(http.MethodGet, "/a")
+		stats := store.(cache.Inspector).Stats()
+		Expect(stats.LoadErrors).Should(BeZero())
+		Expect(stats.Misses).Should(Equal(uint64(1)))
+	})
+
+	It("prefers s-maxage to max-age", func() {
+		cacheControl = "max-age=0, s-maxage=60"
+		serve(http.MethodGet, "/a")
+		serve(http.MethodGet, "/a")
+		Expect(runs).Should(Equal(int32(1)))
+	})
+
+	It("does not cache responses that must not be shared", func() {
+		for i, directive := range []string{"no-store", "private, max-age=60", "no-cache, max-age=60", "public"} {
+			cacheControl = directive
+			url := fmt.Sprintf("/%d", i)
+			serve(http.MethodGet, url)
+			Expect(serve(http.MethodGet, url).Header().Get("Age")).Should(BeEmpty())
+		}
+		Expect(runs).Should(Equal(int32(8)))
+	})
+
+	It("does not cache responses setting cookies or varying on everything", func() {
+		extraHeaders.Set("Set-Cookie", "session=1")
+		serve(http.MethodGet, "/a")
+		serve(http.MethodGet, "/a")
+		extraHeaders = http.Header{"Vary": {"*"}}
+		serve(http.MethodGet, "/b")
+		serve(http.MethodGet, "/b")
+		Expect(runs).Should(Equal(int32(4)))
+	})
+
+	It("only caches responses to requests with credentials if they are public", func() {
+		serve(http.MethodGet, "/a", "Authorization", "Bearer 1")
+		serve(http.MethodGet, "/a", "Authorization", "Bearer 2")
+		Expect(runs).Should(Equal(int32(2)))
+		cacheControl = "public, max-age=60"
+		serve(http.MethodGet, "/b", "Authorization", "Bearer 1")
+		serve(http.MethodGet, "/b", "Authorization", "Bearer 2")
+		Expect(runs).Should(Equal(int32(3)))
+	})
+
+	It("caches a variant for each value of the headers named in Vary", func() {
+		extraHeaders.Set("Vary", "Accept-Encoding")
+		Expect(serve(http.MethodGet, "/a", "Accept-Encoding", "gzip").Body.String()).Should(Equal("run 1 for gzip"))
+		Expect(serve(http.MethodGet, "/a", "Accept-Encoding", "br").Body.String()).Should(Equal("run 2 for br"))
+		Expect(serve(http.MethodGet, "/a", "Accept-Encoding", "gzip").Body.String()).Should(Equal("run 1 for gzip"))
+		Expect(serve(http.MethodGet, "/a", "Accept-Encoding", "br").Body.String()).Should(Equal("run 2 for br"))
+		Expect(serve(http.MethodGet, "/a").Body.String()).Should(Equal("run 3 for "))
+		Expect(runs).Should(Equal(int32(3)))
+	})
+
+	It("serves HEAD requests without a body", func() {
+		Expect(serve(http.MethodHead, "/a").Body.Len()).Should(BeZero())
+		Expect(serve(http.MethodHead, "/a").Header().Get("Age")).Should(Equal("0"))
+		Expect(runs).Should(Equal(int32(1)))
+	})
+
+	It("loads responses again once they expire", func() {
+		cacheControl = "max-age=2"
+		extraHeaders.Set("Age", "1")
+		serve(http.MethodGet, "/a")
+		Expect(serve(http.MethodGet, "/a").Header().Get("Age")).Should(Equal("1"))
+		time.Sleep(1100 * time.Millisecond)
+		serve(http.MethodGet, "/a")
+		Expect(runs).Should(Equal(int32(2)))
+	})
+
+	It("invalidates the URL on successful unsafe requests", func() {
+		serve(http.MethodGet, "/a")
+		serve(http.MethodHead, "/a")
+		serve(http.MethodGet, "/b")
+		serve(http.MethodPost, "/a")
+		serve(http.MethodGet, "/a")
+		Expect(runs).Should(Equal(int32(4)))
+		status = http.StatusNoContent
+		serve(http.MethodPost, "/a")
+		serve(http.MethodGet, "/a")
+		serve(http.MethodHead, "/a")
+		serve(http.MethodGet, "/b")
+		Expect(runs).Should(Equal(int32(7)))
+	})
+
+	It("runs the handler once for concurrent misses", func() {
+		release := make(chan struct{})
+		subject = httpcache.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			atomic.AddInt32(&runs, 1)
+			w.Header().Set("Cache-Control", cacheControl)
+			_, _ = w.Write([]byte("shared"))
+		}), newLRUStore)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(serve(http.MethodGet, "/a").Body.String()).Should(Equal("shared"))
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Expect(runs).Should(Equal(int32(1)))
+	})
+
+	It("does not share private responses between concurrent misses", func() {
+		release := make(chan struct{})
+		subject = httpcache.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			atomic.AddInt32(&runs, 1)
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			_, _ = w.Write([]byte("for " + r.Header.Get("User")))
+		}), newLRUStore)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(user string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(serve(http.MethodGet, "/a", "User", user).Body.String()).Should(Equal("for " + user))
+			}(fmt.Sprint(i))
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Expect(runs).Should(Equal(int32(5)))
+	})
+})
@@ -0,0 +1,147 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+// StoreFactory builds the byte cache responses are kept in, around the ByteMapper it is given, such as:
+//
+//	func(valueMapper cache.ByteMapper) cache.ByteGetInvalidator { return cache.NewLRUByte(64<<20, valueMapper) }
+//
+// The cache must call the ByteMapper on the goroutine calling Get, as every cache in this module does
+type StoreFactory func(valueMapper cache.ByteMapper) cache.ByteGetInvalidator
+
+var (
+	errUncacheable = fmt.Errorf("response must not be cached")
+	errNoLoader    = fmt.Errorf("httpcache stores only load through the Handler or Transport")
+)
+
+// loaderKey is the context key of the loader the ByteMapper calls
+type loaderKey struct{}
+
+type loader func() (value []byte, err error)
+
+func load(ctx context.Context, key interface{}) (value []byte, err error) {
+	l, ok := ctx.Value(loaderKey{}).(loader)
+	if !ok {
+		return nil, errNoLoader
+	}
+	return l()
+}
+
+// getOrLoad gets the key from the store, calling l if it is not cached
+func getOrLoad(ctx context.Context, store cache.ByteGetInvalidator, key string, l loader) (value []byte, err error) {
+	return store.Get(context.WithValue(ctx, loaderKey{}, l), key)
+}
+
+// put replaces what is cached for the key
+func put(ctx context.Context, store cache.ByteGetInvalidator, key string, value []byte) {
+	store.Invalidate(key)
+	_, _ = getOrLoad(ctx, store, key, func() ([]byte, error) {
+		return value, nil
+	})
+}
+
+// keyBase starts every key cached for the method and target, which is the URL without its scheme
+func keyBase(method string, target string) string {
+	return method + " " + target + "\n"
+}
+
+// varyKey is where the names of the headers the responses for the URL vary on are kept
+func varyKey(base string) string {
+	return base + "vary"
+}
+
+// responseKey is where the response for the request is kept, given the headers it varies on
+func responseKey(base string, names []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base + "response")
+	for _, name := range names {
+		b.WriteString("\n" + name + ": " + strings.Join(req.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// lookup gets what is cached for the key without caching anything. Stores that are cache.Containers are asked
+// first, so lookups that find nothing don't count as misses and load errors in their Stats
+func lookup(ctx context.Context, store cache.ByteGetInvalidator, key string) (data []byte, ok bool) {
+	if c, isContainer := store.(cache.Container); isContainer && !c.Contains(key) {
+		return nil, false
+	}
+	data, err := getOrLoad(ctx, store, key, func() ([]byte, error) {
+		return nil, errUncacheable
+	})
+	return data, err == nil
+}
+
+// lookupVaryNames are the headers the last cached response for the URL varied on, if any
+func lookupVaryNames(ctx context.Context, store cache.ByteGetInvalidator, base string) (names []string) {
+	data, ok := lookup(ctx, store, varyKey(base))
+	if !ok || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// lookupEntry is the response cached for the key, or nil if there is none
+func lookupEntry(ctx context.Context, store cache.ByteGetInvalidator, key string) *entry {
+	data, ok := lookup(ctx, store, key)
+	if !ok {
+		return nil
+	}
+	e, err := decodeEntry(data)
+	if err != nil {
+		store.Invalidate(key)
+		return nil
+	}
+	return e
+}
+
+// keepVariant caches a response whose Vary header names different headers than those it was looked up by, along
+// with the names, so later requests are looked up by them
+func keepVariant(ctx context.Context, store cache.ByteGetInvalidator, base string, req *http.Request, e *entry) {
+	data, err := e.encode()
+	if err != nil {
+		return
+	}
+	names, _ := varyNames(e.Header)
+	put(ctx, store, varyKey(base), []byte(strings.Join(names, "\n")))
+	put(ctx, store, responseKey(base, names, req), data)
+}
+
+// sameNames is true if the response varies on exactly the names
+func sameNames(names []string, vary map[string][]string) bool {
+	if len(names) != len(vary) {
+		return false
+	}
+	for _, name := range names {
+		if _, ok := vary[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidateTarget removes everything cached for the target, for every method and variant, if the store is a
+// cache.PrefixInvalidater
+func invalidateTarget(store cache.ByteGetInvalidator, target string) {
+	if p, ok := store.(cache.PrefixInvalidater); ok {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			p.InvalidatePrefix(keyBase(method, target))
+		}
+	}
+}
+
+// unsafe methods change the resource, so what is cached for it is invalidated when they succeed
+func unsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+// Transport is an http.RoundTripper caching the responses of the one it wraps, as a client's private cache would
+type Transport struct {
+	next  http.RoundTripper
+	store cache.ByteGetInvalidator
+}
+
+// NewTransport caches the responses next returns to GET and HEAD requests, keyed on the method, URL and the request
+// headers named in the response's Vary header. next is http.DefaultTransport if it is nil.
+//
+// Responses are fresh for Cache-Control's max-age, or until Expires if they have none, and are served from the cache
+// until then. Stale responses with an ETag or Last-Modified header are revalidated with If-None-Match and
+// If-Modified-Since; a 304 Not Modified refreshes the cached headers and lifetime and the cached body is returned.
+// Responses marked no-cache, or without a lifetime but with a validator, are revalidated before every use. Responses
+// marked no-store or varying on * are not cached, nor are responses to requests marked no-store, or that carry their
+// own Range or conditional headers. Requests marked no-cache or max-age=0 revalidate what is cached. Concurrent
+// misses for the same key make one request, and share its response if it may be cached.
+//
+// Successful POST, PUT, PATCH and DELETE requests invalidate what is cached for their URL, if the store is a
+// cache.PrefixInvalidater. Response bodies are read in full before RoundTrip returns
+func NewTransport(next http.RoundTripper, newStore StoreFactory) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		next:  next,
+		store: newStore(load),
+	}
+}
+
+// fetched is a response as the Transport received it
+type fetched struct {
+	response  *entry
+	cacheable bool
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err = t.next.RoundTrip(req)
+		if err == nil && unsafe(req.Method) && resp.StatusCode < 400 {
+			t.Invalidate(req.URL.String())
+		}
+		return
+	}
+	if bypasses(req) {
+		return t.next.RoundTrip(req)
+	}
+	ctx := req.Context()
+	base := keyBase(req.Method, req.URL.String())
+	names := lookupVaryNames(ctx, t.store, base)
+	key := responseKey(base, names, req)
+	var stale *entry
+	if cached := lookupEntry(ctx, t.store, key); cached != nil && cached.matches(req) {
+		if usable(cached, req, time.Now()) {
+			closeBody(req)
+			return cached.response(req), nil
+		}
+		stale = cached
+	}
+	if stale != nil {
+		t.store.Invalidate(key)
+	}
+	var (
+		result *fetched
+		ran    bool
+	)
+	data, err := getOrLoad(ctx, t.store, key, func() ([]byte, error) {
+		ran = true
+		var fetchErr error
+		if result, fetchErr = t.fetch(req, stale); fetchErr != nil {
+			return nil, fetchErr
+		}
+		// a response varying on headers other than the ones it was looked up by is kept once the names are known
+		if !result.cacheable || !sameNames(names, result.response.Vary) {
+			return nil, errUncacheable
+		}
+		return result.response.encode()
+	})
+	if ran {
+		if result == nil {
+			return nil, err
+		}
+		if result.cacheable && err == errUncacheable {
+			keepVariant(ctx, t.store, base, req, result.response)
+		}
+		return result.response.response(req), nil
+	}
+	if err == nil {
+		if e, decodeErr := decodeEntry(data); decodeErr == nil && e.matches(req) {
+			closeBody(req)
+			return e.response(req), nil
+		}
+	}
+	// the response fetched for another request could not be shared with this one
+	if result, err = t.fetch(req, stale); err != nil {
+		return nil, err
+	}
+	return result.response.response(req), nil
+}
+
+// bypasses is true for requests the cache must not answer or store the response to
+func bypasses(req *http.Request) bool {
+	for _, name := range []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return parseCacheControl(req.Header).has("no-store")
+}
+
+// usable is true if the cached response may answer the request without revalidating it
+func usable(cached *entry, req *http.Request, now time.Time) bool {
+	if !cached.fresh(now) {
+		return false
+	}
+	cc := parseCacheControl(req.Header)
+	if cc.has("no-cache") {
+		return false
+	}
+	if maxAge, ok := cc.seconds("max-age"); ok && cached.age(now) > maxAge {
+		return false
+	}
+	return true
+}
+
+// fetch the response to the request, revalidating the stale entry if there is one with a validator
+func (t *Transport) fetch(req *http.Request, stale *entry) (result *fetched, err error) {
+	out := req
+	if stale != nil {
+		etag, lastModified := stale.Header.Get("ETag"), stale.Header.Get("Last-Modified")
+		if etag == "" && lastModified == "" {
+			stale = nil
+		} else {
+			out = req.Clone(req.Context())
+			if etag != "" {
+				out.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				out.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+	requestTime := time.Now()
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+	status, header := resp.StatusCode, resp.Header
+	if stale != nil && status == http.StatusNotModified {
+		status, header, body = stale.Status, stale.Header.Clone(), stale.Body
+		header.Del("Age")
+		for name, values := range resp.Header {
+			if name != "Content-Length" {
+				header[name] = values
+			}
+		}
+	}
+	expiresAt, cacheable := privateFreshness(status, header, requestTime, responseTime)
+	return &fetched{
+		response:  newEntry(req, status, header, body, responseTime, expiresAt),
+		cacheable: cacheable,
+	}, nil
+}
+
+// Invalidate everything cached for the URL, such as "https://example.com/users?page=2", for every method and variant.
+// It needs the store to be a cache.PrefixInvalidater
+func (t *Transport) Invalidate(url string) {
+	invalidateTarget(t.store, url)
+}
+
+// closeBody closes the request's body, as RoundTrip must, when it is answered without sending it
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
@@ -0,0 +1,228 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/httpcache"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		hits         int32
+		status       int
+		cacheControl string
+		headers      http.Header
+		conditionals chan http.Header
+		release      chan struct{}
+		origin       *httptest.Server
+		client       *http.Client
+	)
+	BeforeEach(func() {
+		hits = 0
+		status = http.StatusOK
+		cacheControl = "max-age=60"
+		headers = http.Header{}
+		conditionals = make(chan http.Header, 10)
+		release = nil
+		origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if release != nil {
+				<-release
+			}
+			n := atomic.AddInt32(&hits, 1)
+			w.Header().Set("Cache-Control", cacheControl)
+			for name, values := range headers {
+				w.Header()[name] = values
+			}
+			if inm, ims := r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since"); inm != "" || ims != "" {
+				conditionals <- http.Header{"If-None-Match": {inm}, "If-Modified-Since": {ims}}
+				if (inm != "" && inm == headers.Get("ETag")) || (inm == "" && ims == headers.Get("Last-Modified")) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+			w.WriteHeader(status)
+			if r.Method != http.MethodPost {
+				_, _ = fmt.Fprintf(w, "hit %d for %s", n, r.Header.Get("Accept-Language"))
+			}
+		}))
+		client = &http.Client{Transport: httpcache.NewTransport(nil, newLRUStore)}
+	})
+	AfterEach(func() {
+		origin.Close()
+	})
+	fetch := func(method, path string, requestHeaders ...string) (resp *http.Response, body string) {
+		req, err := http.NewRequest(method, origin.URL+path, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		for i := 0; i < len(requestHeaders); i += 2 {
+			req.Header.Set(requestHeaders[i], requestHeaders[i+1])
+		}
+		resp, err = client.Do(req)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer func() { _ = resp.Body.Close() }()
+		data, err := ioutil.ReadAll(resp.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		return resp, string(data)
+	}
+
+	It("serves fresh responses from the cache", func() {
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 1 for "))
+		resp, body := fetch(http.MethodGet, "/a")
+		Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(resp.Header.Get("Age")).Should(Equal("0"))
+		Expect(hits).Should(BeEquivalentTo(1))
+	})
+	It("does not count looking up uncached responses as load errors", func() {
+		var store cache.ByteGetInvalidator
+		client = &http.Client{Transport: httpcache.NewTransport(nil, func(valueMapper cache.ByteMapper) cache.ByteGetInvalidator {
+			store = newLRUStore(valueMapper)
+			return store
+		})}
+		fetch(http.MethodGet, "/a")
+		fetch(http.MethodGet, "/a")
+		stats := store.(cache.Inspector).Stats()
+		Expect(stats.LoadErrors).Should(BeZero())
+		Expect(stats.Misses).Should(Equal(uint64(1)))
+	})
+	It("caches private responses", func() {
+		cacheControl = "private, max-age=60"
+		fetch(http.MethodGet, "/a", "Authorization", "Bearer token")
+		_, body := fetch(http.MethodGet, "/a", "Authorization", "Bearer token")
+		Expect(body).Should(Equal("hit 1 for "))
+	})
+	It("takes the lifetime from Expires without a max-age", func() {
+		cacheControl = ""
+		headers.Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		fetch(http.MethodGet, "/future")
+		_, body := fetch(http.MethodGet, "/future")
+		Expect(body).Should(Equal("hit 1 for "))
+
+		headers.Set("Expires", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		fetch(http.MethodGet, "/past")
+		_, body = fetch(http.MethodGet, "/past")
+		Expect(body).Should(Equal("hit 3 for "))
+	})
+	It("does not cache responses marked no-store or answering requests marked no-store", func() {
+		cacheControl = "no-store"
+		fetch(http.MethodGet, "/a")
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 2 for "))
+
+		cacheControl = "max-age=60"
+		fetch(http.MethodGet, "/b", "Cache-Control", "no-store")
+		_, body = fetch(http.MethodGet, "/b")
+		Expect(body).Should(Equal("hit 4 for "))
+	})
+	It("revalidates stale responses with If-None-Match and keeps the body on 304", func() {
+		cacheControl = "max-age=0"
+		headers.Set("ETag", `"v1"`)
+		fetch(http.MethodGet, "/a")
+
+		cacheControl = "max-age=60"
+		headers.Set("X-Refreshed", "yes")
+		resp, body := fetch(http.MethodGet, "/a")
+		Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(resp.Header.Get("X-Refreshed")).Should(Equal("yes"))
+		Expect(<-conditionals).Should(HaveKeyWithValue("If-None-Match", []string{`"v1"`}))
+
+		// the 304 made it fresh again
+		_, body = fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(hits).Should(BeEquivalentTo(2))
+	})
+	It("revalidates with If-Modified-Since using Last-Modified", func() {
+		cacheControl = "no-cache"
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		headers.Set("Last-Modified", lastModified)
+		fetch(http.MethodGet, "/a")
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(<-conditionals).Should(HaveKeyWithValue("If-Modified-Since", []string{lastModified}))
+		// no-cache revalidates every time
+		fetch(http.MethodGet, "/a")
+		Expect(hits).Should(BeEquivalentTo(3))
+	})
+	It("stores responses with only a validator, revalidating them before each use", func() {
+		cacheControl = ""
+		headers.Set("ETag", `"v1"`)
+		fetch(http.MethodGet, "/a")
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(conditionals).Should(HaveLen(1))
+	})
+	It("replaces the cached response when the origin has a new one", func() {
+		cacheControl = "max-age=0"
+		headers.Set("ETag", `"v1"`)
+		fetch(http.MethodGet, "/a")
+		headers.Set("ETag", `"v2"`)
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 2 for "))
+		_, body = fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 2 for "))
+		Expect(<-conditionals).Should(HaveKeyWithValue("If-None-Match", []string{`"v1"`}))
+		Expect(<-conditionals).Should(HaveKeyWithValue("If-None-Match", []string{`"v2"`}))
+	})
+	It("revalidates when the request is marked no-cache", func() {
+		headers.Set("ETag", `"v1"`)
+		fetch(http.MethodGet, "/a")
+		_, body := fetch(http.MethodGet, "/a", "Cache-Control", "no-cache")
+		Expect(body).Should(Equal("hit 1 for "))
+		Expect(conditionals).Should(HaveLen(1))
+	})
+	It("passes requests with their own conditional headers through", func() {
+		headers.Set("ETag", `"v1"`)
+		fetch(http.MethodGet, "/a")
+		resp, _ := fetch(http.MethodGet, "/a", "If-None-Match", `"v1"`)
+		Expect(resp.StatusCode).Should(Equal(http.StatusNotModified))
+	})
+	It("caches a variant for each value of the headers named in Vary", func() {
+		headers.Set("Vary", "Accept-Language")
+		fetch(http.MethodGet, "/a", "Accept-Language", "en")
+		fetch(http.MethodGet, "/a", "Accept-Language", "fr")
+		_, en := fetch(http.MethodGet, "/a", "Accept-Language", "en")
+		_, fr := fetch(http.MethodGet, "/a", "Accept-Language", "fr")
+		Expect(en).Should(Equal("hit 1 for en"))
+		Expect(fr).Should(Equal("hit 2 for fr"))
+	})
+	It("answers HEAD requests without a body", func() {
+		fetch(http.MethodHead, "/a")
+		resp, body := fetch(http.MethodHead, "/a")
+		Expect(body).Should(BeEmpty())
+		Expect(resp.Header.Get("Age")).ShouldNot(BeEmpty())
+		Expect(hits).Should(BeEquivalentTo(1))
+	})
+	It("invalidates the URL on successful unsafe requests", func() {
+		fetch(http.MethodGet, "/a")
+		fetch(http.MethodPost, "/a")
+		_, body := fetch(http.MethodGet, "/a")
+		Expect(body).Should(Equal("hit 3 for "))
+	})
+	It("makes one request for concurrent misses", func() {
+		release = make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, body := fetch(http.MethodGet, "/a")
+				Expect(body).Should(Equal("hit 1 for "))
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Expect(hits).Should(BeEquivalentTo(1))
+	})
+})
@@ -35,3 +35,48 @@ ctx: the context passed to Get calls by the caller
 key: passed to Get calls by the caller
 */
 type ValueMapper func(ctx context.Context, key interface{}) (value interface{}, err error)
+
+type Clearer interface {
+	// Clear removes every item from the cache
+	Clear()
+}
+
+// Container tells whether keys are cached, without loading them
+type Container interface {
+	// Contains is true if the key is cached and has not been invalidated or expired. It does not count as using the key
+	Contains(key interface{}) bool
+}
+
+// Stats are counters describing how a cache has been used
+type Stats struct {
+	// Hits is how many times Get found the value already cached
+	Hits uint64 `json:"hits"`
+
+	// Misses is how many times Get did not find the value cached
+	Misses uint64 `json:"misses"`
+
+	// LoadErrors is how many times loading a value failed
+	LoadErrors uint64 `json:"loadErrors"`
+
+	// Evictions is how many items were removed to make room for others
+	Evictions uint64 `json:"evictions"`
+
+	// Items is how many items are cached right now
+	Items int `json:"items"`
+}
+
+// Inspector exposes what a cache is holding, for debugging and monitoring
+type Inspector interface {
+	// Policy names how the cache decides what to keep, e.g. "unbounded" or "lru"
+	Policy() string
+
+	// Usage is how much of the capacity is in use, in whatever units the cache measures size.
+	// capacity is zero if the cache is unbounded
+	Usage() (used uint, capacity uint)
+
+	// RecentKeys returns, at most, max cached keys with the most recently used first
+	RecentKeys(max int) []interface{}
+
+	// Stats returns a snapshot of the cache's counters
+	Stats() Stats
+}
@@ -0,0 +1,105 @@
+// Package connpool keeps connections to a server open between the commands the stores send it
+package connpool
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+var ErrClosed = fmt.Errorf("store is closed")
+
+// Conn is a connection with buffered reads and writes
+type Conn struct {
+	net.Conn
+	R *bufio.Reader
+	W *bufio.Writer
+}
+
+// Pool keeps up to maxIdle connections to addr open between exchanges, and opens more when they are all in use
+type Pool struct {
+	addr    string
+	maxIdle int
+	dialer  net.Dialer
+
+	mu     sync.Mutex
+	idle   []*Conn
+	closed bool
+}
+
+// New pools TCP connections to addr, such as "10.0.0.1:6379"
+func New(addr string, maxIdle int) *Pool {
+	return &Pool{
+		addr:    addr,
+		maxIdle: maxIdle,
+	}
+}
+
+// Do runs the exchange on a connection, until ctx's deadline. Connections are only reused after exchanges that
+// succeed, since a failed one may leave part of a reply unread
+func (p *Pool) Do(ctx context.Context, exchange func(c *Conn) error) (err error) {
+	c, err := p.take(ctx)
+	if err != nil {
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	if err = c.SetDeadline(deadline); err == nil {
+		err = exchange(c)
+	}
+	if err != nil {
+		_ = c.Close()
+		return
+	}
+	p.give(c)
+	return
+}
+
+// Close the idle connections, and those in use once their exchanges finish
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, c := range p.idle {
+		_ = c.Close()
+	}
+	p.idle = nil
+	return nil
+}
+
+// take an idle connection, or open a new one
+func (p *Pool) take(ctx context.Context) (c *Conn, err error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		c = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	conn, err := p.dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return
+	}
+	return &Conn{
+		Conn: conn,
+		R:    bufio.NewReader(conn),
+		W:    bufio.NewWriter(conn),
+	}, nil
+}
+
+// give the connection back to be reused, or close it if enough are idle already
+func (p *Pool) give(c *Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle) >= p.maxIdle {
+		_ = c.Close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
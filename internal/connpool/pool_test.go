@@ -0,0 +1,92 @@
+package connpool_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/internal/connpool"
+	"github.com/wojnosystems/go-cache/internal/fakeserver"
+)
+
+var _ = Describe("Pool", func() {
+	var (
+		ctx     = context.Background()
+		accepts *int32
+		server  *fakeserver.Server
+		subject *connpool.Pool
+		echo    func(c *connpool.Conn) error
+	)
+	BeforeEach(func() {
+		accepts = new(int32)
+		var err error
+		server, err = fakeserver.Start(func(conn net.Conn) {
+			atomic.AddInt32(accepts, 1)
+			buf := make([]byte, 1)
+			for {
+				if _, err := conn.Read(buf); err != nil {
+					return
+				}
+				if _, err := conn.Write(buf); err != nil {
+					return
+				}
+			}
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		subject = connpool.New(server.Addr(), 1)
+		echo = func(c *connpool.Conn) (err error) {
+			if err = c.W.WriteByte('x'); err != nil {
+				return
+			}
+			if err = c.W.Flush(); err != nil {
+				return
+			}
+			b, err := c.R.ReadByte()
+			if err == nil && b != 'x' {
+				err = fmt.Errorf("echoed %q", b)
+			}
+			return
+		}
+	})
+	AfterEach(func() {
+		Expect(subject.Close()).Should(Succeed())
+		Expect(server.Close()).Should(Succeed())
+	})
+
+	It("reuses idle connections", func() {
+		Expect(subject.Do(ctx, echo)).Should(Succeed())
+		Expect(subject.Do(ctx, echo)).Should(Succeed())
+		Expect(atomic.LoadInt32(accepts)).Should(Equal(int32(1)))
+	})
+
+	It("opens more connections when they are all in use", func() {
+		Expect(subject.Do(ctx, func(c *connpool.Conn) error {
+			Expect(subject.Do(ctx, echo)).Should(Succeed())
+			return echo(c)
+		})).Should(Succeed())
+		Eventually(func() int32 {
+			return atomic.LoadInt32(accepts)
+		}).Should(Equal(int32(2)))
+		Expect(subject.Do(ctx, echo)).Should(Succeed())
+		Expect(atomic.LoadInt32(accepts)).Should(Equal(int32(2)))
+	})
+
+	It("does not reuse connections after failed exchanges", func() {
+		failed := fmt.Errorf("failed")
+		Expect(subject.Do(ctx, func(c *connpool.Conn) error {
+			return failed
+		})).Should(MatchError(failed))
+		Expect(subject.Do(ctx, echo)).Should(Succeed())
+		Eventually(func() int32 {
+			return atomic.LoadInt32(accepts)
+		}).Should(Equal(int32(2)))
+	})
+
+	It("refuses exchanges once closed", func() {
+		Expect(subject.Close()).Should(Succeed())
+		Expect(subject.Do(ctx, echo)).Should(MatchError(connpool.ErrClosed))
+	})
+})
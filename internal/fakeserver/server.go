@@ -0,0 +1,45 @@
+// Package fakeserver runs the in-memory servers the stores are tested against
+package fakeserver
+
+import (
+	"net"
+)
+
+// Server accepts connections on a local port, serving each on its own goroutine
+type Server struct {
+	listener net.Listener
+}
+
+// Start listening on a free local port, serving each connection with serve. Connections are closed once serve
+// returns
+func Start(serve func(conn net.Conn)) (s *Server, err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				serve(conn)
+			}()
+		}
+	}()
+	return &Server{listener: listener}, nil
+}
+
+// Addr is the address to connect to, such as "127.0.0.1:51234"
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops accepting connections. Those already accepted are served until their clients disconnect
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
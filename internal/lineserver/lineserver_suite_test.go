@@ -0,0 +1,13 @@
+package lineserver_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLineserver(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lineserver Suite")
+}
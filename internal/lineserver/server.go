@@ -0,0 +1,126 @@
+// Package lineserver accepts the clients of the servers, and reads the lines of their text protocols
+package lineserver
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+var ErrLineTooLong = fmt.Errorf("line is too long")
+
+// Serve answers a client until it returns. ctx is cancelled once the Server is closed
+type Serve func(ctx context.Context, r *bufio.Reader, w *bufio.Writer)
+
+// Server serves each client connecting to its listener on its own goroutine
+type Server struct {
+	listener net.Listener
+	serve    Serve
+
+	// ctx is cancelled by Close, abandoning loads in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	total  uint64
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Start serving clients connecting to the listener with serve until s is closed. Clients are disconnected once serve
+// returns. s is set up in place, so it can be embedded in the server it accepts clients for, and be used by serve
+func Start(s *Server, listener net.Listener, serve Serve) {
+	s.listener = listener
+	s.serve = serve
+	s.conns = make(map[net.Conn]bool)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Addr is the address clients connect to
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting clients and disconnects those already connected
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.cancel()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Clients is how many clients are connected, and how many have connected since the Server started
+func (s *Server) Clients() (current int, total uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns), s.total
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.total++
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	s.serve(s.ctx, bufio.NewReader(conn), bufio.NewWriter(conn))
+}
+
+// ReadLine reads up to the next newline, which is not included, refusing lines longer than max
+func ReadLine(r *bufio.Reader, max int) (line []byte, err error) {
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > max {
+			return nil, ErrLineTooLong
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// FirstLine keeps error replies to one line, as the protocols require
+func FirstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package lineserver_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		subject   *lineserver.Server
+		cancelled chan struct{}
+		connect   func() (net.Conn, *bufio.Reader)
+	)
+	BeforeEach(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		cancelled = make(chan struct{}, 10)
+		cancels := cancelled
+		subject = &lineserver.Server{}
+		// echoes each line until the client sends "quit", or the server is closed
+		lineserver.Start(subject, listener, func(ctx context.Context, r *bufio.Reader, w *bufio.Writer) {
+			go func() {
+				<-ctx.Done()
+				cancels <- struct{}{}
+			}()
+			for {
+				line, err := lineserver.ReadLine(r, 8)
+				if err != nil || string(line) == "quit" {
+					return
+				}
+				_, _ = w.WriteString(string(line) + "\r\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		})
+		connect = func() (net.Conn, *bufio.Reader) {
+			conn, err := net.Dial("tcp", subject.Addr().String())
+			Expect(err).ShouldNot(HaveOccurred())
+			return conn, bufio.NewReader(conn)
+		}
+	})
+	AfterEach(func() {
+		_ = subject.Close()
+	})
+
+	It("serves each client", func() {
+		first, firstR := connect()
+		second, secondR := connect()
+		_, _ = first.Write([]byte("one\r\n"))
+		_, _ = second.Write([]byte("two\r\n"))
+		Expect(firstR.ReadString('\n')).Should(Equal("one\r\n"))
+		Expect(secondR.ReadString('\n')).Should(Equal("two\r\n"))
+		current, total := subject.Clients()
+		Expect(current).Should(Equal(2))
+		Expect(total).Should(Equal(uint64(2)))
+	})
+
+	It("disconnects clients once they have been served", func() {
+		conn, r := connect()
+		_, _ = conn.Write([]byte("quit\r\n"))
+		_, err := r.ReadByte()
+		Expect(err).Should(Equal(io.EOF))
+		Eventually(func() int {
+			current, _ := subject.Clients()
+			return current
+		}).Should(BeZero())
+		_, total := subject.Clients()
+		Expect(total).Should(Equal(uint64(1)))
+	})
+
+	It("disconnects clients and cancels their context when closed", func() {
+		conn, r := connect()
+		_, _ = conn.Write([]byte("one\r\n"))
+		Expect(r.ReadString('\n')).Should(Equal("one\r\n"))
+		Expect(subject.Close()).Should(Succeed())
+		Eventually(cancelled).Should(Receive())
+		_, err := r.ReadByte()
+		Expect(err).Should(HaveOccurred())
+		_, err = net.Dial("tcp", subject.Addr().String())
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("refuses lines that are too long", func() {
+		_, err := lineserver.ReadLine(bufio.NewReaderSize(strings.NewReader("123456789\r\n"), 16), 8)
+		Expect(err).Should(MatchError(lineserver.ErrLineTooLong))
+		line, err := lineserver.ReadLine(bufio.NewReaderSize(strings.NewReader(strings.Repeat("x", 40)+"\n"), 16), 64)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(line)).Should(Equal(strings.Repeat("x", 40)))
+	})
+
+	It("keeps error replies to their first line", func() {
+		Expect(lineserver.FirstLine("failed\r\nwith more")).Should(Equal("failed"))
+		Expect(lineserver.FirstLine("failed")).Should(Equal("failed"))
+	})
+})
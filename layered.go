@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// LayerFactory builds a cache around the ValueMapper it is given, such as:
+//
+//	func(valueMapper cache.ValueMapper) cache.GetInvalidater { return cache.NewLRUItem(100, valueMapper) }
+type LayerFactory func(valueMapper ValueMapper) GetInvalidater
+
+// notedGetter is a Getter that can also say how the value was annotated when it was cached
+type notedGetter interface {
+	getNoted(ctx context.Context, key interface{}) (notes *annotated, err error)
+}
+
+// getNoted gets the value along with the tags, namespace, dependencies and expiry it was cached with
+func (u *unbounded) getNoted(ctx context.Context, key interface{}) (notes *annotated, err error) {
+	value, err := u.Get(ctx, key)
+	if err != nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.cache[key]; ok && !u.stale(u.stamps[key]) {
+		notes = u.notesLocked(key)
+		notes.value = value
+		return
+	}
+	_, notes = unannotate(value)
+	return
+}
+
+type layered struct {
+	l1 GetInvalidater
+	l2 Getter
+
+	// annotates is true when L1 is one of this package's caches, so it can be given l2's annotations
+	annotates bool
+}
+
+// NewLayered puts a cache built by newL1 in front of l2, usually a small LRU in front of a larger shared or disk
+// cache. L1 misses are loaded from l2, and l2 misses from its own ValueMapper.
+//
+// When both layers are this package's caches, L1 keeps the tags, namespace, dependencies and expiry that l2 cached
+// the value with, so invalidating them through the layered cache reaches both layers. L1s built some other way are
+// given the bare values and don't get l1TTL. Every invalidation is applied to
+// l2 first, then L1, for each layer that supports it. Invalidating l2 directly leaves L1's copy in place.
+//
+// l1TTL: if positive, L1 expires its copies after this long, or sooner if l2's copy expires first, so L1 does not
+// serve values long after another process has changed them in l2
+func NewLayered(newL1 LayerFactory, l2 Getter, l1TTL time.Duration) GetInvalidater {
+	l := &layered{
+		l2: l2,
+	}
+	l.l1 = newL1(l.fromL2(l1TTL))
+	_, l.annotates = l.l1.(unannotater)
+	return l
+}
+
+// fromL2 is L1's ValueMapper
+func (l *layered) fromL2(l1TTL time.Duration) ValueMapper {
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		if noted, ok := l.l2.(notedGetter); ok {
+			value, err = noted.getNoted(ctx, key)
+		} else {
+			value, err = l.l2.Get(ctx, key)
+		}
+		if err != nil {
+			return
+		}
+		if !l.annotates {
+			value, _ = unannotate(value)
+			return value, nil
+		}
+		if l1TTL <= 0 {
+			return
+		}
+		a := annotate(value)
+		if expiresAt := time.Now().Add(l1TTL).UnixNano(); a.expiresAt == 0 || expiresAt < a.expiresAt {
+			a.expiresAt = expiresAt
+		}
+		return a, nil
+	}
+}
+
+func (l *layered) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return l.l1.Get(ctx, key)
+}
+
+func (l *layered) getNoted(ctx context.Context, key interface{}) (notes *annotated, err error) {
+	if noted, ok := l.l1.(notedGetter); ok {
+		return noted.getNoted(ctx, key)
+	}
+	value, err := l.l1.Get(ctx, key)
+	_, notes = unannotate(value)
+	return
+}
+
+// layers are l2 then L1, the order invalidations are applied in, so L1 can't reload a value l2 is about to drop
+func (l *layered) layers() []interface{} {
+	return []interface{}{l.l2, l.l1}
+}
+
+func (l *layered) Invalidate(key interface{}) {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(Invalidater); ok {
+			i.Invalidate(key)
+		}
+	}
+}
+
+func (l *layered) InvalidateTag(tag interface{}) {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(TagInvalidater); ok {
+			i.InvalidateTag(tag)
+		}
+	}
+}
+
+func (l *layered) InvalidateWhere(matches func(key interface{}) bool) {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(PredicateInvalidater); ok {
+			i.InvalidateWhere(matches)
+		}
+	}
+}
+
+func (l *layered) InvalidatePrefix(prefix string) {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(PrefixInvalidater); ok {
+			i.InvalidatePrefix(prefix)
+		}
+	}
+}
+
+func (l *layered) IndexPrefixes() {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(PrefixInvalidater); ok {
+			i.IndexPrefixes()
+		}
+	}
+}
+
+func (l *layered) InvalidateAll() {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(GenerationInvalidater); ok {
+			i.InvalidateAll()
+		}
+	}
+}
+
+func (l *layered) InvalidateNamespace(namespace interface{}) {
+	for _, layer := range l.layers() {
+		if i, ok := layer.(GenerationInvalidater); ok {
+			i.InvalidateNamespace(namespace)
+		}
+	}
+}
+
+// Contains is true if either layer that can tell has the key
+func (l *layered) Contains(key interface{}) bool {
+	for _, layer := range l.layers() {
+		if c, ok := layer.(Container); ok && c.Contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *layered) Clear() {
+	for _, layer := range l.layers() {
+		if c, ok := layer.(Clearer); ok {
+			c.Clear()
+		}
+	}
+}
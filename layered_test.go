@@ -0,0 +1,120 @@
+package cache_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+)
+
+// countingGetter is a Getter that is not a cache, it counts its calls
+type countingGetter struct {
+	gets int
+}
+
+func (c *countingGetter) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	c.gets++
+	return key, nil
+}
+
+// mapCache is a GetInvalidater that is not one of the package's caches, it keeps whatever its ValueMapper returns
+type mapCache struct {
+	valueMapper cache.ValueMapper
+	values      map[interface{}]interface{}
+}
+
+func (m *mapCache) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	if value, ok := m.values[key]; ok {
+		return value, nil
+	}
+	if value, err = m.valueMapper(ctx, key); err == nil {
+		m.values[key] = value
+	}
+	return
+}
+
+func (m *mapCache) Invalidate(key interface{}) {
+	delete(m.values, key)
+}
+
+var _ = Describe("Layered", func() {
+	var (
+		loads   []interface{}
+		l2      cache.GetInvalidater
+		subject cache.GetInvalidater
+	)
+	newL1 := func(valueMapper cache.ValueMapper) cache.GetInvalidater {
+		return cache.NewLRUItem(1, valueMapper)
+	}
+	BeforeEach(func() {
+		loads = nil
+		l2 = cache.NewLRUItem(10, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			loads = append(loads, key)
+			return taggingMapper(ctx, key)
+		})
+		subject = cache.NewLayered(newL1, l2, 0)
+	})
+
+	It("consults L2 before the origin", func() {
+		Expect(subject.Get(ignoreCtx, "a:1")).Should(Equal("a:1"))
+		Expect(subject.Get(ignoreCtx, "b:1")).Should(Equal("b:1"))
+		Expect(subject.Get(ignoreCtx, "a:1")).Should(Equal("a:1"))
+		Expect(loads).Should(Equal([]interface{}{"a:1", "b:1"}))
+	})
+
+	It("invalidates both layers", func() {
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		subject.Invalidate("a:1")
+		Expect(cachedKeys(l2)).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		Expect(loads).Should(Equal([]interface{}{"a:1", "a:1"}))
+	})
+
+	It("invalidates L2's tags in both layers", func() {
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		subject.(cache.TagInvalidater).InvalidateTag("a")
+		Expect(cachedKeys(l2)).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		Expect(loads).Should(HaveLen(2))
+	})
+
+	It("clears both layers", func() {
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		subject.(cache.Clearer).Clear()
+		_, _ = subject.Get(ignoreCtx, "a:1")
+		Expect(loads).Should(HaveLen(2))
+	})
+
+	It("expires L1's copies after the L1 TTL", func() {
+		origin := &countingGetter{}
+		subject = cache.NewLayered(newL1, origin, 20*time.Millisecond)
+		_, _ = subject.Get(ignoreCtx, "a")
+		_, _ = subject.Get(ignoreCtx, "a")
+		Expect(origin.gets).Should(Equal(1))
+		time.Sleep(30 * time.Millisecond)
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(origin.gets).Should(Equal(2))
+	})
+
+	It("keeps L2's expiry when it is sooner than the L1 TTL", func() {
+		l2 = cache.NewLRUItem(10, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			loads = append(loads, key)
+			return cache.WithTTL(key, 20*time.Millisecond), nil
+		})
+		subject = cache.NewLayered(newL1, l2, time.Hour)
+		_, _ = subject.Get(ignoreCtx, "a")
+		time.Sleep(30 * time.Millisecond)
+		_, _ = subject.Get(ignoreCtx, "a")
+		Expect(loads).Should(HaveLen(2))
+	})
+	It("gives L1s that are not the package's caches bare values", func() {
+		subject = cache.NewLayered(func(valueMapper cache.ValueMapper) cache.GetInvalidater {
+			return &mapCache{valueMapper: valueMapper, values: make(map[interface{}]interface{})}
+		}, l2, time.Hour)
+		Expect(subject.Get(ignoreCtx, "a:1")).Should(Equal("a:1"))
+		Expect(subject.Get(ignoreCtx, "a:1")).Should(Equal("a:1"))
+		Expect(loads).Should(HaveLen(1))
+	})
+})
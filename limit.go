@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrLoadSlotTimeout = fmt.Errorf("timed out waiting for a load slot")
+
+// NewConcurrencyLimitMapper allows at most maxConcurrent loads to run at once, the rest wait for a slot.
+// Callers that wait longer than maxWait fail with ErrLoadSlotTimeout, those whose ctx is done first with its error.
+// maxWait of zero waits as long as ctx allows. maxConcurrent below 1 allows 1
+func NewConcurrencyLimitMapper(valueMapper ValueMapper, maxConcurrent int, maxWait time.Duration) ValueMapper {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	slots := make(chan struct{}, maxConcurrent)
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		waitCtx, cancel := withMaxWait(ctx, maxWait)
+		defer cancel()
+		select {
+		case slots <- struct{}{}:
+		case <-waitCtx.Done():
+			return nil, waitErr(ctx)
+		}
+		defer func() {
+			<-slots
+		}()
+		return valueMapper(ctx, key)
+	}
+}
+
+type tokenBucket struct {
+	perSecond float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimitMapper allows loads to start at perSecond on average, with bursts of up to burst loads at once.
+// Callers that would have to wait longer than maxWait, or past their ctx deadline, fail with ErrLoadSlotTimeout,
+// those whose ctx is done while waiting with its error. maxWait of zero waits as long as ctx allows
+func NewRateLimitMapper(valueMapper ValueMapper, perSecond float64, burst int, maxWait time.Duration) ValueMapper {
+	if burst < 1 {
+		burst = 1
+	}
+	bucket := &tokenBucket{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		waitCtx, cancel := withMaxWait(ctx, maxWait)
+		defer cancel()
+		if err = bucket.take(waitCtx); err != nil {
+			return nil, waitErr(ctx)
+		}
+		return valueMapper(ctx, key)
+	}
+}
+
+// take a token, waiting for one to become available if there are none
+func (b *tokenBucket) take(ctx context.Context) error {
+	wait, ok := b.reserve(ctx)
+	if !ok {
+		return ErrLoadSlotTimeout
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.giveBack()
+		return ErrLoadSlotTimeout
+	}
+}
+
+// reserve takes a token now, even if it has not been earned yet, returning how long until it is.
+// Nothing is taken if that would be after ctx's deadline
+func (b *tokenBucket) reserve(ctx context.Context) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSecond
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		if b.perSecond <= 0 {
+			return 0, false
+		}
+		wait = time.Duration((1 - b.tokens) / b.perSecond * float64(time.Second))
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && now.Add(wait).After(deadline) {
+			return 0, false
+		}
+	}
+	b.tokens--
+	return wait, true
+}
+
+func (b *tokenBucket) giveBack() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+func withMaxWait(ctx context.Context, maxWait time.Duration) (context.Context, context.CancelFunc) {
+	if maxWait <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, maxWait)
+}
+
+// waitErr is why a caller stopped waiting: its own ctx being done, or otherwise maxWait expiring
+func waitErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrLoadSlotTimeout
+}
@@ -0,0 +1,140 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("Limit", func() {
+	When("limiting concurrency", func() {
+		var (
+			running    *int32
+			maxRunning *int32
+			release    chan struct{}
+			source     cache.ValueMapper
+		)
+		BeforeEach(func() {
+			running, maxRunning, release = new(int32), new(int32), make(chan struct{})
+			// leftover loads from earlier specs keep using their own counters
+			running, maxRunning, release := running, maxRunning, release
+			source = func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				now := atomic.AddInt32(running, 1)
+				defer atomic.AddInt32(running, -1)
+				for {
+					seen := atomic.LoadInt32(maxRunning)
+					if now <= seen || atomic.CompareAndSwapInt32(maxRunning, seen, now) {
+						break
+					}
+				}
+				<-release
+				return key, nil
+			}
+		})
+
+		It("runs at most the limit at once", func() {
+			subject := cache.NewLRUItem(100, cache.NewConcurrencyLimitMapper(source, 3, 0))
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(key string) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(subject.Get(ignoreCtx, key)).Should(Equal(key))
+				}(strconv.Itoa(i))
+			}
+			Eventually(func() int32 {
+				return atomic.LoadInt32(running)
+			}).Should(Equal(int32(3)))
+			close(release)
+			wg.Wait()
+			Expect(*maxRunning).Should(Equal(int32(3)))
+		})
+
+		It("times out waiting for a slot", func() {
+			subject := cache.NewConcurrencyLimitMapper(source, 1, 10*time.Millisecond)
+			go func() {
+				_, _ = subject(ignoreCtx, "1")
+			}()
+			Eventually(func() int32 {
+				return atomic.LoadInt32(running)
+			}).Should(Equal(int32(1)))
+			_, err := subject(ignoreCtx, "2")
+			Expect(err).Should(MatchError(cache.ErrLoadSlotTimeout))
+			close(release)
+		})
+
+		It("stops waiting when the caller gives up", func() {
+			subject := cache.NewConcurrencyLimitMapper(source, 1, 0)
+			go func() {
+				_, _ = subject(ignoreCtx, "1")
+			}()
+			Eventually(func() int32 {
+				return atomic.LoadInt32(running)
+			}).Should(Equal(int32(1)))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := subject(ctx, "2")
+			Expect(err).Should(MatchError(context.Canceled))
+			close(release)
+		})
+
+		It("allows one load at a time when maxConcurrent is not positive", func() {
+			subject := cache.NewConcurrencyLimitMapper(source, 0, 10*time.Millisecond)
+			go func() {
+				_, _ = subject(ignoreCtx, "1")
+			}()
+			Eventually(func() int32 {
+				return atomic.LoadInt32(running)
+			}).Should(Equal(int32(1)))
+			_, err := subject(ignoreCtx, "2")
+			Expect(err).Should(MatchError(cache.ErrLoadSlotTimeout))
+			close(release)
+		})
+	})
+
+	When("limiting the rate", func() {
+		var (
+			source cache.ValueMapper
+		)
+		BeforeEach(func() {
+			source = func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				return key, nil
+			}
+		})
+
+		It("allows a burst", func() {
+			subject := cache.NewRateLimitMapper(source, 1, 3, time.Millisecond)
+			for i := 0; i < 3; i++ {
+				Expect(subject(ignoreCtx, i)).Should(Equal(i))
+			}
+			_, err := subject(ignoreCtx, 4)
+			Expect(err).Should(MatchError(cache.ErrLoadSlotTimeout))
+		})
+
+		It("waits for tokens", func() {
+			subject := cache.NewRateLimitMapper(source, 100, 1, 0)
+			start := time.Now()
+			for i := 0; i < 5; i++ {
+				Expect(subject(ignoreCtx, i)).Should(Equal(i))
+			}
+			Expect(time.Since(start)).Should(BeNumerically(">=", 35*time.Millisecond))
+		})
+
+		It("does not wait past the caller's deadline", func() {
+			subject := cache.NewRateLimitMapper(source, 1, 1, 0)
+			_, _ = subject(ignoreCtx, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := subject(ctx, 2)
+			Expect(err).Should(MatchError(cache.ErrLoadSlotTimeout))
+			Expect(time.Since(start)).Should(BeNumerically("<", 10*time.Millisecond))
+		})
+	})
+})
@@ -13,4 +13,7 @@ type Tracker interface {
 
 	// Len how many items tracked in this structure
 	Len() int
+
+	// Walk calls fn with each key, starting with the most recently used, until fn returns false
+	Walk(fn func(key interface{}) bool)
 }
@@ -49,3 +49,11 @@ func (l *tracker) LRU() (key interface{}, ok bool) {
 func (l *tracker) Len() int {
 	return l.recency.Len()
 }
+
+func (l *tracker) Walk(fn func(key interface{}) bool) {
+	for e := l.recency.Back(); e != nil; e = e.Prev() {
+		if !fn(e.Value) {
+			return
+		}
+	}
+}
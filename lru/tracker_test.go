@@ -25,6 +25,12 @@ var _ = Describe("Tracker", func() {
 		It("removes nothing", func() {
 			subject.Remove(1)
 		})
+		It("walks nothing", func() {
+			subject.Walk(func(key interface{}) bool {
+				Fail("walked an empty tracker")
+				return true
+			})
+		})
 	})
 
 	When("one item", func() {
@@ -88,6 +94,25 @@ var _ = Describe("Tracker", func() {
 				Expect(subject.Len()).Should(Equal(3))
 			})
 		})
+		When("walked", func() {
+			It("starts with the most recently used", func() {
+				var keys []interface{}
+				subject.Touch(2)
+				subject.Walk(func(key interface{}) bool {
+					keys = append(keys, key)
+					return true
+				})
+				Expect(keys).Should(Equal([]interface{}{2, 4, 3, 1}))
+			})
+			It("stops when asked", func() {
+				var keys []interface{}
+				subject.Walk(func(key interface{}) bool {
+					keys = append(keys, key)
+					return len(keys) < 2
+				})
+				Expect(keys).Should(Equal([]interface{}{4, 3}))
+			})
+		})
 		When("existing item is touched", func() {
 			It("does not change the length", func() {
 				before := subject.Len()
@@ -1,7 +1,6 @@
 package cache
 
 import (
-	"context"
 	"github.com/wojnosystems/go-cache/capacity"
 	"github.com/wojnosystems/go-cache/lru"
 )
@@ -17,6 +16,9 @@ type lruBase struct {
 	tracker    lru.Tracker
 	limit      capacity.TrackMutator
 	valueSizer ValueSizer
+
+	// onEvict is called with mu held, just before the least recently used key is evicted
+	onEvict func(key, value interface{})
 }
 
 // NewLRU creates a cache that has the ability to limit the size however you wish to track it
@@ -24,43 +26,67 @@ type lruBase struct {
 // valueSizer: Added items will use the size returned by valueSizer. Items removed will use the same
 // valueMapper: looks up values based on keys
 func NewLRU(cap uint, valueSizer ValueSizer, valueMapper ValueMapper) GetInvalidater {
+	return newLRU(cap, valueSizer, valueMapper)
+}
+
+func newLRU(cap uint, valueSizer ValueSizer, valueMapper ValueMapper) *lruBase {
 	l := &lruBase{
+		unbounded:  newUnbounded(valueMapper),
 		tracker:    lru.NewTracker(),
 		limit:      capacity.NewMaxLen(cap),
 		valueSizer: valueSizer,
+		onEvict:    func(key, value interface{}) {},
 	}
-	l.unbounded = newUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
-		value, err = valueMapper(ctx, key)
-		if err != nil {
-			return
-		}
-		valueSize := l.valueSizer(value)
-		if l.limit.IsLargerThanCapacity(valueSize) {
-			return nil, ErrInsufficientCapacity
-		}
-		for !l.limit.Add(valueSize) {
-			leastRecentlyUsedItem, _ := l.tracker.LRU()
-			l.Invalidate(leastRecentlyUsedItem)
-		}
-		return
-	})
+	l.onHit = l.tracker.Touch
+	l.onStore = l.admit
+	l.onRemove = l.release
+	l.walk = l.tracker.Walk
 	return l
 }
 
-func (l *lruBase) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
-	value, err = l.unbounded.Get(ctx, key)
-	if err != nil {
-		return
+// admit makes room for the value by evicting the least recently used items
+func (l *lruBase) admit(key, value interface{}) error {
+	valueSize := l.valueSizer(value)
+	if l.limit.IsLargerThanCapacity(valueSize) {
+		return ErrInsufficientCapacity
+	}
+	for !l.limit.Add(valueSize) {
+		leastRecentlyUsedItem, _ := l.tracker.LRU()
+		l.onEvict(leastRecentlyUsedItem, l.cache[leastRecentlyUsedItem])
+		l.removeLocked(leastRecentlyUsedItem)
+		l.stats.Evictions++
 	}
 	l.tracker.Touch(key)
-	return
+	return nil
 }
 
-func (l *lruBase) Invalidate(key interface{}) {
-	if value, ok := l.unbounded.cache[key]; ok {
-		l.unbounded.Invalidate(key)
-		l.tracker.Remove(key)
-		l.limit.Remove(l.valueSizer(value))
-	}
+func (l *lruBase) release(key, value interface{}) {
+	l.tracker.Remove(key)
+	l.limit.Remove(l.valueSizer(value))
+}
+
+func (l *lruBase) Policy() string {
+	return "lru"
+}
+
+func (l *lruBase) Usage() (used uint, capacity uint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweepLocked()
+	return l.limit.Len(), l.limit.Cap()
+}
+
+// RecentKeys returns keys with the most recently used first
+func (l *lruBase) RecentKeys(max int) (keys []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweepLocked()
+	l.tracker.Walk(func(key interface{}) bool {
+		if len(keys) >= max {
+			return false
+		}
+		keys = append(keys, key)
+		return true
+	})
 	return
 }
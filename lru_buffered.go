@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	readBufferStripes = 16
+	readBufferSize    = 32
+)
+
+// bufferedEntry is what hits find in the readIndex. key and value never change once stored
+type bufferedEntry struct {
+	key    interface{}
+	value  interface{}
+	stripe uint32
+	stamp  stamp
+
+	// removed is set once the entry leaves the cache, so late reads of it are not applied. Guarded by mu
+	removed bool
+}
+
+// readBuffer is a lossy ring of recent hits. Any goroutine may add to it, only the drainer removes from it
+type readBuffer struct {
+	hits   uint64
+	writes uint32
+	reads  uint32
+	slots  [readBufferSize]atomic.Value
+}
+
+type bufferedLRU struct {
+	*lruBase
+
+	// readIndex mirrors the cache so hits can be found without taking mu
+	readIndex  sync.Map
+	buffers    [readBufferStripes]readBuffer
+	nextStripe uint32
+	draining   int32
+}
+
+// NewBufferedLRU is NewLRU, but hits don't take the lock. Instead of updating the tracker, each hit is recorded in
+// one of several small buffers, which are applied to the tracker in batches when one fills up, and before anything
+// is evicted. This makes eviction approximately least recently used:
+//
+// keys that have not been read since the last batch are evicted in exactly least recently used order.
+// Keys read since then are moved to most recently used, but not necessarily in the order they were read.
+// Under heavy contention a hit may not be recorded at all, if its buffer is full or another goroutine is adding
+// to it at the same moment, in which case the key keeps its previous place in the order
+func NewBufferedLRU(cap uint, valueSizer ValueSizer, valueMapper ValueMapper) ManyGetInvalidater {
+	return newBufferedLRU(cap, valueSizer, valueMapper)
+}
+
+// NewShardedBufferedLRU is NewShardedLRU with NewBufferedLRU for each shard
+func NewShardedBufferedLRU(shardCount int, cap uint, valueSizer ValueSizer, valueMapper ValueMapper, hasher KeyHasher) ManyGetInvalidater {
+	return newShardedCache(shardCount, cap, hasher, func(shardCap uint) cacheShard {
+		return newBufferedLRU(shardCap, valueSizer, valueMapper)
+	})
+}
+
+func newBufferedLRU(cap uint, valueSizer ValueSizer, valueMapper ValueMapper) *bufferedLRU {
+	b := &bufferedLRU{
+		lruBase: newLRU(cap, valueSizer, valueMapper),
+	}
+	admit, release := b.onStore, b.onRemove
+	b.onStore = func(key, value interface{}) error {
+		b.drainLocked()
+		if err := admit(key, value); err != nil {
+			return err
+		}
+		b.readIndex.Store(key, &bufferedEntry{
+			key:    key,
+			value:  value,
+			stripe: b.nextStripe % readBufferStripes,
+			stamp:  b.stamps[key],
+		})
+		b.nextStripe++
+		return nil
+	}
+	b.onRemove = func(key, value interface{}) {
+		if e, ok := b.readIndex.Load(key); ok {
+			e.(*bufferedEntry).removed = true
+			b.readIndex.Delete(key)
+		}
+		release(key, value)
+	}
+	b.walk = func(fn func(key interface{}) bool) {
+		b.drainLocked()
+		b.tracker.Walk(fn)
+	}
+	return b
+}
+
+func (b *bufferedLRU) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	if e, ok := b.readIndex.Load(key); ok {
+		entry := e.(*bufferedEntry)
+		if !b.stale(entry.stamp) {
+			b.record(entry)
+			return entry.value, nil
+		}
+	}
+	return b.lruBase.Get(ctx, key)
+}
+
+// record the hit in the entry's buffer, dropping it if the buffer is full or contended
+func (b *bufferedLRU) record(entry *bufferedEntry) {
+	buffer := &b.buffers[entry.stripe]
+	atomic.AddUint64(&buffer.hits, 1)
+	writes := atomic.LoadUint32(&buffer.writes)
+	pending := writes - atomic.LoadUint32(&buffer.reads)
+	if pending >= readBufferSize {
+		b.tryDrain()
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&buffer.writes, writes, writes+1) {
+		return
+	}
+	buffer.slots[writes%readBufferSize].Store(entry)
+	if pending+1 == readBufferSize {
+		b.tryDrain()
+	}
+}
+
+// tryDrain drains the buffers, unless another goroutine is already doing so
+func (b *bufferedLRU) tryDrain() {
+	if !atomic.CompareAndSwapInt32(&b.draining, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&b.draining, 0)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drainLocked()
+}
+
+// drainLocked applies the recorded hits to the tracker
+func (b *bufferedLRU) drainLocked() {
+	var none *bufferedEntry
+	for i := range b.buffers {
+		buffer := &b.buffers[i]
+		reads := atomic.LoadUint32(&buffer.reads)
+		writes := atomic.LoadUint32(&buffer.writes)
+		for ; reads != writes; reads++ {
+			slot := &buffer.slots[reads%readBufferSize]
+			entry, _ := slot.Load().(*bufferedEntry)
+			if entry == nil {
+				// claimed, but not yet stored. It's lost
+				continue
+			}
+			slot.Store(none)
+			if !entry.removed {
+				b.tracker.Touch(entry.key)
+			}
+		}
+		atomic.StoreUint32(&buffer.reads, reads)
+	}
+}
+
+func (b *bufferedLRU) Policy() string {
+	return "buffered lru"
+}
+
+func (b *bufferedLRU) RecentKeys(max int) []interface{} {
+	func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drainLocked()
+	}()
+	return b.lruBase.RecentKeys(max)
+}
+
+func (b *bufferedLRU) Stats() (stats Stats) {
+	stats = b.lruBase.Stats()
+	for i := range b.buffers {
+		stats.Hits += atomic.LoadUint64(&b.buffers[i].hits)
+	}
+	return
+}
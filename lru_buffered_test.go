@@ -0,0 +1,94 @@
+package cache_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var _ = Describe("BufferedLRU", func() {
+	var (
+		subject cache.ManyGetInvalidater
+	)
+	BeforeEach(func() {
+		subject = cache.NewBufferedLRU(3, itemSizer, echoMapper)
+		_, _ = subject.Get(ignoreCtx, "1")
+		_, _ = subject.Get(ignoreCtx, "2")
+		_, _ = subject.Get(ignoreCtx, "3")
+	})
+
+	It("serves hits", func() {
+		Expect(subject.Get(ignoreCtx, "1")).Should(Equal("1"))
+		Expect(subject.(cache.Inspector).Stats()).Should(Equal(cache.Stats{
+			Hits:   1,
+			Misses: 3,
+			Items:  3,
+		}))
+	})
+
+	It("applies hits before evicting", func() {
+		_, _ = subject.Get(ignoreCtx, "1")
+		_, _ = subject.Get(ignoreCtx, "4")
+		Expect(subject.(cache.Inspector).RecentKeys(10)).Should(ConsistOf("1", "3", "4"))
+	})
+
+	It("orders keys that were not read since the last drain exactly", func() {
+		_, _ = subject.Get(ignoreCtx, "4")
+		_, _ = subject.Get(ignoreCtx, "5")
+		Expect(subject.(cache.Inspector).RecentKeys(10)).Should(Equal([]interface{}{"5", "4", "3"}))
+	})
+
+	It("stops serving invalidated keys", func() {
+		subject.Invalidate("1")
+		_, _ = subject.Get(ignoreCtx, "1")
+		Expect(subject.(cache.Inspector).Stats().Misses).Should(Equal(uint64(4)))
+	})
+
+	It("stops serving cleared keys", func() {
+		subject.(cache.Clearer).Clear()
+		_, _ = subject.Get(ignoreCtx, "1")
+		Expect(subject.(cache.Inspector).Stats().Misses).Should(Equal(uint64(4)))
+	})
+
+	It("stays within capacity when read from many goroutines", func() {
+		subject = cache.NewBufferedLRU(50, itemSizer, echoMapper)
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 1_000; j++ {
+					key := strconv.Itoa((i * j) % 100)
+					Expect(subject.Get(ignoreCtx, key)).Should(Equal(key))
+				}
+			}(i)
+		}
+		wg.Wait()
+		used, _ := subject.(cache.Inspector).Usage()
+		Expect(used).Should(BeNumerically("<=", 50))
+		Expect(subject.(cache.Inspector).RecentKeys(100)).Should(HaveLen(int(used)))
+	})
+
+	It("shards", func() {
+		sharded := cache.NewShardedBufferedLRU(4, 40, itemSizer, echoMapper, nil)
+		for i := 0; i < 100; i++ {
+			_, _ = sharded.Get(ignoreCtx, i)
+			_, _ = sharded.Get(ignoreCtx, i)
+		}
+		used, _ := sharded.(cache.Inspector).Usage()
+		Expect(used).Should(BeNumerically("<=", 40))
+		Expect(sharded.(cache.Inspector).Stats().Hits).Should(Equal(uint64(100)))
+	})
+})
+
+func BenchmarkBufferedLRUHits(b *testing.B) {
+	benchmarkHits(b, cache.NewBufferedLRU(benchmarkKeys, itemSizer, echoMapper))
+}
+
+func BenchmarkShardedBufferedLRUHits(b *testing.B) {
+	benchmarkHits(b, cache.NewShardedBufferedLRU(64, benchmarkKeys*2, itemSizer, echoMapper, nil))
+}
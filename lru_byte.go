@@ -1,6 +1,9 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // ByteGetInvalidator is just like GetInvalidator, but specific for byte array values
 type ByteGetInvalidator interface {
@@ -15,8 +18,21 @@ func byteLenFromInterface(byteSlice interface{}) uint {
 	return uint(cap(byteSlice.([]byte)))
 }
 
+// inspectableCache is everything lruByte needs from the cache holding its values
+type inspectableCache interface {
+	GetInvalidater
+	TagInvalidater
+	PredicateInvalidater
+	PrefixInvalidater
+	GenerationInvalidater
+	Clearer
+	Container
+	Snapshotter
+	Inspector
+}
+
 type lruByte struct {
-	lruBase GetInvalidater
+	values inspectableCache
 }
 
 type ByteMapper func(ctx context.Context, key interface{}) (value []byte, err error)
@@ -27,22 +43,25 @@ type ByteMapper func(ctx context.Context, key interface{}) (value []byte, err er
 // maxBytes: cache will not hold more bytes than this value
 func NewLRUByte(maxBytes uint, valueMapper ByteMapper) ByteGetInvalidator {
 	l := &lruByte{
-		lruBase: NewLRU(maxBytes,
+		values: newLRU(maxBytes,
 			byteLenFromInterface,
-			func(ctx context.Context, key interface{}) (value interface{}, err error) {
-
-				value, err = valueMapper(ctx, key)
-				return
-			}),
+			valueMapperFromByte(valueMapper)),
 	}
 	return l
 }
 
+func valueMapperFromByte(valueMapper ByteMapper) ValueMapper {
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		value, err = valueMapper(ctx, key)
+		return
+	}
+}
+
 // Get only exists to convert the interface to an explicit byte-array as GoLang lacks generics
 // this is just a convenience function
 func (b *lruByte) Get(ctx context.Context, key interface{}) (value []byte, err error) {
 	var iVal interface{}
-	iVal, err = b.lruBase.Get(ctx, key)
+	iVal, err = b.values.Get(ctx, key)
 	if err != nil {
 		return
 	}
@@ -50,5 +69,62 @@ func (b *lruByte) Get(ctx context.Context, key interface{}) (value []byte, err e
 }
 
 func (b *lruByte) Invalidate(key interface{}) {
-	b.lruBase.Invalidate(key)
+	b.values.Invalidate(key)
+}
+
+func (b *lruByte) InvalidateTag(tag interface{}) {
+	b.values.InvalidateTag(tag)
+}
+
+func (b *lruByte) InvalidateWhere(matches func(key interface{}) bool) {
+	b.values.InvalidateWhere(matches)
+}
+
+func (b *lruByte) InvalidatePrefix(prefix string) {
+	b.values.InvalidatePrefix(prefix)
+}
+
+func (b *lruByte) IndexPrefixes() {
+	b.values.IndexPrefixes()
+}
+
+func (b *lruByte) InvalidateAll() {
+	b.values.InvalidateAll()
+}
+
+func (b *lruByte) InvalidateNamespace(namespace interface{}) {
+	b.values.InvalidateNamespace(namespace)
+}
+
+func (b *lruByte) Clear() {
+	b.values.Clear()
+}
+
+func (b *lruByte) Contains(key interface{}) bool {
+	return b.values.Contains(key)
+}
+
+// Save snapshots the cache, use NewBytesCodec to store the byte slices as they are
+func (b *lruByte) Save(w io.Writer, codec ValueCodec) error {
+	return b.values.Save(w, codec)
+}
+
+func (b *lruByte) Load(r io.Reader, codec ValueCodec) error {
+	return b.values.Load(r, codec)
+}
+
+func (b *lruByte) Policy() string {
+	return b.values.Policy()
+}
+
+func (b *lruByte) Usage() (used uint, capacity uint) {
+	return b.values.Usage()
+}
+
+func (b *lruByte) RecentKeys(max int) []interface{} {
+	return b.values.RecentKeys(max)
+}
+
+func (b *lruByte) Stats() Stats {
+	return b.values.Stats()
 }
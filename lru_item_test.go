@@ -1,10 +1,13 @@
 package cache_test
 
 import (
+	"context"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/wojnosystems/go-cache"
+	"strconv"
+	"sync"
 )
 
 var _ = Describe("LRUItem", func() {
@@ -115,6 +118,68 @@ var _ = Describe("LRUItem", func() {
 		})
 	})
 
+	When("inspected", func() {
+		BeforeEach(func() {
+			subject = cache.NewLRUItem(2, valueMapperWrap(source))
+			source.EXPECT().Get(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, key string) (string, error) {
+				return key, nil
+			})
+			_, _ = subject.Get(ignoreCtx, "1")
+			_, _ = subject.Get(ignoreCtx, "2")
+			_, _ = subject.Get(ignoreCtx, "3")
+			_, _ = subject.Get(ignoreCtx, "2")
+		})
+		It("lists the most recent keys first", func() {
+			Expect(subject.(cache.Inspector).RecentKeys(10)).Should(Equal([]interface{}{"2", "3"}))
+		})
+		It("reports usage", func() {
+			inspector := subject.(cache.Inspector)
+			Expect(inspector.Policy()).Should(Equal("lru"))
+			used, capacity := inspector.Usage()
+			Expect(used).Should(Equal(uint(2)))
+			Expect(capacity).Should(Equal(uint(2)))
+		})
+		It("counts evictions", func() {
+			Expect(subject.(cache.Inspector).Stats().Evictions).Should(Equal(uint64(1)))
+		})
+		It("releases capacity when cleared", func() {
+			subject.(cache.Clearer).Clear()
+			used, _ := subject.(cache.Inspector).Usage()
+			Expect(used).Should(BeZero())
+			Expect(subject.(cache.Inspector).RecentKeys(10)).Should(BeEmpty())
+		})
+	})
+
+	When("used from many goroutines", func() {
+		BeforeEach(func() {
+			subject = cache.NewLRUItem(5, valueMapperWrap(source))
+			source.EXPECT().Get(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, key string) (string, error) {
+				return key, nil
+			})
+		})
+		It("stays within capacity", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						key := strconv.Itoa((i + j) % 10)
+						Expect(subject.Get(ignoreCtx, key)).Should(Equal(key))
+						if j%7 == 0 {
+							subject.Invalidate(key)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+			used, _ := subject.(cache.Inspector).Usage()
+			Expect(used).Should(BeNumerically("<=", 5))
+			Expect(subject.(cache.Inspector).Stats().Items).Should(Equal(int(used)))
+		})
+	})
+
 	When("capacity is zero", func() {
 		BeforeEach(func() {
 			subject = cache.NewLRUItem(0, valueMapperWrap(source))
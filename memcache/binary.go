@@ -0,0 +1,206 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+const (
+	requestMagic  byte = 0x80
+	responseMagic byte = 0x81
+
+	// headerSize is the fixed header starting every binary request and response
+	headerSize = 24
+
+	// maxBody is the largest request body accepted. Requests only carry keys and small extras
+	maxBody = 1 << 20
+)
+
+const (
+	opGet      byte = 0x00
+	opSet      byte = 0x01
+	opAdd      byte = 0x02
+	opReplace  byte = 0x03
+	opDelete   byte = 0x04
+	opQuit     byte = 0x07
+	opFlush    byte = 0x08
+	opGetQ     byte = 0x09
+	opNoop     byte = 0x0a
+	opVersion  byte = 0x0b
+	opGetK     byte = 0x0c
+	opGetKQ    byte = 0x0d
+	opAppend   byte = 0x0e
+	opPrepend  byte = 0x0f
+	opStat     byte = 0x10
+	opSetQ     byte = 0x11
+	opAddQ     byte = 0x12
+	opReplaceQ byte = 0x13
+	opDeleteQ  byte = 0x14
+	opQuitQ    byte = 0x17
+	opFlushQ   byte = 0x18
+	opAppendQ  byte = 0x19
+	opPrependQ byte = 0x1a
+)
+
+const (
+	statusOK            uint16 = 0x0000
+	statusKeyNotFound   uint16 = 0x0001
+	statusInvalidArgs   uint16 = 0x0004
+	statusUnknown       uint16 = 0x0081
+	statusNotSupported  uint16 = 0x0083
+	statusInternalError uint16 = 0x0084
+)
+
+// request is a binary protocol request, with its body split into extras, key and value
+type request struct {
+	opcode byte
+	opaque uint32
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+// response is a binary protocol response
+type response struct {
+	opcode byte
+	status uint16
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+func readRequest(r *bufio.Reader) (req request, ok bool) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != requestMagic {
+		return req, false
+	}
+	keyLen := int(binary.BigEndian.Uint16(header[2:4]))
+	extrasLen := int(header[4])
+	bodyLen := int(binary.BigEndian.Uint32(header[8:12]))
+	if bodyLen > maxBody || keyLen+extrasLen > bodyLen {
+		return req, false
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return req, false
+	}
+	req.opcode = header[1]
+	req.opaque = binary.BigEndian.Uint32(header[12:16])
+	req.extras = body[:extrasLen]
+	req.key = body[extrasLen : extrasLen+keyLen]
+	req.value = body[extrasLen+keyLen:]
+	return req, true
+}
+
+func writeResponse(w *bufio.Writer, resp response) {
+	var header [headerSize]byte
+	header[0] = responseMagic
+	header[1] = resp.opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(resp.key)))
+	header[4] = byte(len(resp.extras))
+	binary.BigEndian.PutUint16(header[6:8], resp.status)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(resp.extras)+len(resp.key)+len(resp.value)))
+	binary.BigEndian.PutUint32(header[12:16], resp.opaque)
+	binary.BigEndian.PutUint64(header[16:24], resp.cas)
+	_, _ = w.Write(header[:])
+	_, _ = w.Write(resp.extras)
+	_, _ = w.Write(resp.key)
+	_, _ = w.Write(resp.value)
+}
+
+// serveBinary answers requests in the binary protocol
+func (s *Server) serveBinary(ctx context.Context, r *bufio.Reader, w *bufio.Writer) {
+	for {
+		req, ok := readRequest(r)
+		if !ok {
+			return
+		}
+		if !s.executeBinary(ctx, w, req) {
+			_ = w.Flush()
+			return
+		}
+		if !flush(r, w) {
+			return
+		}
+	}
+}
+
+// executeBinary runs the request and writes its response, keepOpen is false if the client should be disconnected.
+// Quiet requests only respond on failure, except for quiet gets, which respond on success
+func (s *Server) executeBinary(ctx context.Context, w *bufio.Writer, req request) (keepOpen bool) {
+	resp := response{
+		opcode: req.opcode,
+		opaque: req.opaque,
+	}
+	quiet := false
+	switch req.opcode {
+	case opGet, opGetQ, opGetK, opGetKQ:
+		if len(req.key) == 0 {
+			resp.status = statusInvalidArgs
+			break
+		}
+		value, err := s.cache.Get(ctx, string(req.key))
+		if err != nil {
+			resp.status = statusInternalError
+			resp.value = []byte(err.Error())
+			break
+		}
+		resp.extras = make([]byte, 4)
+		resp.value = value
+		resp.cas = casOf(value)
+		if req.opcode == opGetK || req.opcode == opGetKQ {
+			resp.key = req.key
+		}
+	case opDelete, opDeleteQ:
+		quiet = req.opcode == opDeleteQ
+		if len(req.key) == 0 {
+			resp.status = statusInvalidArgs
+		} else if s.delete(string(req.key)) == "NOT_FOUND" {
+			resp.status = statusKeyNotFound
+		}
+	case opFlush, opFlushQ:
+		quiet = req.opcode == opFlushQ
+		if len(req.extras) == 4 && binary.BigEndian.Uint32(req.extras) != 0 {
+			resp.status = statusNotSupported
+			resp.value = []byte("delayed flush is not supported")
+		} else if clearer, ok := s.cache.(cache.Clearer); ok {
+			clearer.Clear()
+		} else {
+			resp.status = statusNotSupported
+		}
+	case opStat:
+		if len(req.key) > 0 {
+			resp.status = statusKeyNotFound
+			break
+		}
+		for _, stat := range s.stats() {
+			resp.key = []byte(stat[0])
+			resp.value = []byte(stat[1])
+			writeResponse(w, resp)
+		}
+		resp.key, resp.value = nil, nil
+	case opNoop:
+	case opVersion:
+		resp.value = []byte(version)
+	case opQuit, opQuitQ:
+		if req.opcode == opQuit {
+			writeResponse(w, resp)
+		}
+		return false
+	case opSet, opAdd, opReplace, opAppend, opPrepend, opSetQ, opAddQ, opReplaceQ, opAppendQ, opPrependQ:
+		resp.status = statusNotSupported
+		resp.value = []byte("this cache is read only")
+	default:
+		resp.status = statusUnknown
+	}
+	if !quiet || resp.status != statusOK {
+		writeResponse(w, resp)
+	}
+	return true
+}
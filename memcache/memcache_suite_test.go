@@ -0,0 +1,13 @@
+package memcache_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMemcache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Memcache Suite")
+}
@@ -0,0 +1,117 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"hash/fnv"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+// version is what the version command replies with
+const version = "1.6.0-go-cache"
+
+// Server serves a byte cache to memcached clients, over both the text and binary protocols, detected from the
+// first byte each client sends. Clients can read the cache, but not write to it:
+//
+//	get, gets      the values, loaded with the cache's ByteMapper if they are not cached
+//	delete         invalidates the key, replying with whether it was cached
+//	flush_all      clears the cache
+//	stats          the cache's Stats, under memcached's names
+//	version, quit  and the binary protocol's noop
+//
+// Storage commands, such as set, are refused. Keys are passed to the cache as strings, every value has flags of 0,
+// and the CAS of a value is a hash of it. delete needs the cache to be a cache.Container to tell whether the key was
+// cached, flush_all a cache.Clearer, and stats a cache.Inspector for more than the connection counts
+type Server struct {
+	lineserver.Server
+	cache   cache.ByteGetInvalidator
+	started time.Time
+}
+
+// NewServer serves the cache to clients connecting to the listener until Close is called
+func NewServer(listener net.Listener, c cache.ByteGetInvalidator) *Server {
+	s := &Server{
+		cache:   c,
+		started: time.Now(),
+	}
+	lineserver.Start(&s.Server, listener, s.serve)
+	return s
+}
+
+// serve answers the client in whichever protocol its first byte is in, until it quits, disconnects or sends
+// something malformed
+func (s *Server) serve(ctx context.Context, r *bufio.Reader, w *bufio.Writer) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] == requestMagic {
+		s.serveBinary(ctx, r, w)
+	} else {
+		s.serveText(ctx, r, w)
+	}
+}
+
+// flush writes the replies once the client has no more pipelined commands waiting, ok is false if it failed
+func flush(r *bufio.Reader, w *bufio.Writer) (ok bool) {
+	if r.Buffered() > 0 {
+		return true
+	}
+	return w.Flush() == nil
+}
+
+// stats are the name and value of each statistic, using memcached's names where there is one
+func (s *Server) stats() (stats [][2]string) {
+	now := time.Now()
+	current, total := s.Clients()
+	add := func(name string, value interface{}) {
+		var formatted string
+		switch v := value.(type) {
+		case string:
+			formatted = v
+		case int:
+			formatted = strconv.Itoa(v)
+		case int64:
+			formatted = strconv.FormatInt(v, 10)
+		case uint:
+			formatted = strconv.FormatUint(uint64(v), 10)
+		case uint64:
+			formatted = strconv.FormatUint(v, 10)
+		}
+		stats = append(stats, [2]string{name, formatted})
+	}
+	add("pid", os.Getpid())
+	add("uptime", int64(now.Sub(s.started)/time.Second))
+	add("time", now.Unix())
+	add("version", version)
+	add("curr_connections", current)
+	add("total_connections", total)
+	if inspector, ok := s.cache.(cache.Inspector); ok {
+		used, capacity := inspector.Usage()
+		cacheStats := inspector.Stats()
+		add("policy", inspector.Policy())
+		add("cmd_get", cacheStats.Hits+cacheStats.Misses)
+		add("get_hits", cacheStats.Hits)
+		add("get_misses", cacheStats.Misses)
+		add("load_errors", cacheStats.LoadErrors)
+		add("evictions", cacheStats.Evictions)
+		add("curr_items", cacheStats.Items)
+		add("bytes", used)
+		add("limit_maxbytes", capacity)
+	}
+	return
+}
+
+// casOf is a value's CAS. The cache does not version its values, so it is a hash of the value, which only changes
+// when the value does
+func casOf(value []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(value)
+	return h.Sum64()
+}
@@ -0,0 +1,273 @@
+package memcache_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/memcache"
+)
+
+type testConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testConn) write(s string) {
+	_, err := c.conn.Write([]byte(s))
+	Expect(err).ShouldNot(HaveOccurred())
+}
+
+// lines reads until a line is one of the ends, and returns everything read
+func (c *testConn) lines(ends ...string) string {
+	var read strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		Expect(err).ShouldNot(HaveOccurred())
+		read.WriteString(line)
+		for _, end := range ends {
+			if line == end+"\r\n" {
+				return read.String()
+			}
+		}
+	}
+}
+
+type binaryResponse struct {
+	opcode byte
+	status uint16
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    string
+	value  string
+}
+
+func (c *testConn) request(opcode byte, opaque uint32, extras []byte, key string) {
+	header := make([]byte, 24)
+	header[0] = 0x80
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(extras)+len(key)))
+	binary.BigEndian.PutUint32(header[12:16], opaque)
+	c.write(string(header) + string(extras) + key)
+}
+
+func (c *testConn) response() (resp binaryResponse) {
+	header := make([]byte, 24)
+	_, err := io.ReadFull(c.r, header)
+	Expect(err).ShouldNot(HaveOccurred())
+	Expect(header[0]).Should(Equal(byte(0x81)))
+	body := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+	_, err = io.ReadFull(c.r, body)
+	Expect(err).ShouldNot(HaveOccurred())
+	keyLen, extrasLen := int(binary.BigEndian.Uint16(header[2:4])), int(header[4])
+	return binaryResponse{
+		opcode: header[1],
+		status: binary.BigEndian.Uint16(header[6:8]),
+		opaque: binary.BigEndian.Uint32(header[12:16]),
+		cas:    binary.BigEndian.Uint64(header[16:24]),
+		extras: body[:extrasLen],
+		key:    string(body[extrasLen : extrasLen+keyLen]),
+		value:  string(body[extrasLen+keyLen:]),
+	}
+}
+
+var _ = Describe("Server", func() {
+	var (
+		mu      sync.Mutex
+		loads   []string
+		server  *memcache.Server
+		client  *testConn
+		connect func() *testConn
+	)
+	loaded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), loads...)
+	}
+	BeforeEach(func() {
+		loads = nil
+		values := cache.NewLRUByte(100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			mu.Lock()
+			loads = append(loads, key.(string))
+			mu.Unlock()
+			if key == "broken" {
+				return nil, fmt.Errorf("origin is down")
+			}
+			// exactly as long as it is, byte caches measure values by their capacity
+			value = make([]byte, len("value of ")+len(key.(string)))
+			copy(value, "value of "+key.(string))
+			return value, nil
+		})
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		server = memcache.NewServer(listener, values)
+		connect = func() *testConn {
+			conn, err := net.Dial("tcp", server.Addr().String())
+			Expect(err).ShouldNot(HaveOccurred())
+			return &testConn{
+				conn: conn,
+				r:    bufio.NewReader(conn),
+			}
+		}
+		client = connect()
+	})
+	AfterEach(func() {
+		_ = client.conn.Close()
+		Expect(server.Close()).Should(Succeed())
+	})
+
+	Context("text protocol", func() {
+		It("loads values on get", func() {
+			client.write("get a b\r\n")
+			Expect(client.lines("END")).Should(Equal("VALUE a 0 10\r\nvalue of a\r\nVALUE b 0 10\r\nvalue of b\r\nEND\r\n"))
+			client.write("get a\r\n")
+			client.lines("END")
+			Expect(loaded()).Should(Equal([]string{"a", "b"}))
+		})
+
+		It("replies with the same CAS until the value changes", func() {
+			client.write("gets a\r\n")
+			first := client.lines("END")
+			Expect(first).Should(MatchRegexp(`^VALUE a 0 10 \d+\r\n`))
+			client.write("gets a\r\n")
+			Expect(client.lines("END")).Should(Equal(first))
+		})
+
+		It("replies with load errors", func() {
+			client.write("get a broken\r\n")
+			Expect(client.lines("END", "SERVER_ERROR origin is down")).Should(Equal("SERVER_ERROR origin is down\r\n"))
+		})
+
+		It("invalidates keys on delete", func() {
+			client.write("get a\r\n")
+			client.lines("END")
+			client.write("delete a\r\ndelete a\r\ndelete a noreply\r\nversion\r\n")
+			Expect(client.lines("VERSION 1.6.0-go-cache")).Should(Equal("DELETED\r\nNOT_FOUND\r\nVERSION 1.6.0-go-cache\r\n"))
+		})
+
+		It("clears the cache on flush_all", func() {
+			client.write("get a\r\nflush_all\r\n")
+			client.lines("OK")
+			client.write("get a\r\n")
+			client.lines("END")
+			Expect(loaded()).Should(Equal([]string{"a", "a"}))
+		})
+
+		It("maps stats onto the cache's Stats", func() {
+			client.write("get a\r\nget a\r\n")
+			client.lines("END")
+			client.lines("END")
+			client.write("stats\r\n")
+			stats := client.lines("END")
+			Expect(stats).Should(ContainSubstring("STAT get_hits 1\r\n"))
+			Expect(stats).Should(ContainSubstring("STAT get_misses 1\r\n"))
+			Expect(stats).Should(ContainSubstring("STAT curr_items 1\r\n"))
+			Expect(stats).Should(ContainSubstring("STAT bytes 10\r\n"))
+			Expect(stats).Should(ContainSubstring("STAT limit_maxbytes 100\r\n"))
+			Expect(stats).Should(ContainSubstring("STAT curr_connections 1\r\n"))
+		})
+
+		It("refuses storage commands", func() {
+			client.write("set a 0 0 5\r\nhello\r\nget a\r\n")
+			Expect(client.lines("END")).Should(Equal("SERVER_ERROR this cache is read only\r\nVALUE a 0 10\r\nvalue of a\r\nEND\r\n"))
+		})
+
+		It("rejects unknown commands and bad keys", func() {
+			client.write("incr a 1\r\nget " + strings.Repeat("k", 251) + "\r\nquit\r\n")
+			Expect(client.lines("CLIENT_ERROR bad command line format")).Should(Equal("ERROR\r\nCLIENT_ERROR bad command line format\r\n"))
+			_, err := client.r.ReadByte()
+			Expect(err).Should(Equal(io.EOF))
+		})
+	})
+
+	Context("binary protocol", func() {
+		It("loads values on get", func() {
+			client.request(0x0c, 7, nil, "a")
+			resp := client.response()
+			Expect(resp.opcode).Should(Equal(byte(0x0c)))
+			Expect(resp.status).Should(BeZero())
+			Expect(resp.opaque).Should(Equal(uint32(7)))
+			Expect(resp.extras).Should(Equal([]byte{0, 0, 0, 0}))
+			Expect(resp.key).Should(Equal("a"))
+			Expect(resp.value).Should(Equal("value of a"))
+			Expect(resp.cas).ShouldNot(BeZero())
+		})
+
+		It("replies with load errors", func() {
+			client.request(0x00, 0, nil, "broken")
+			resp := client.response()
+			Expect(resp.status).Should(Equal(uint16(0x84)))
+			Expect(resp.value).Should(Equal("origin is down"))
+		})
+
+		It("invalidates keys on delete, quietly if asked", func() {
+			client.request(0x00, 0, nil, "a")
+			client.response()
+			client.request(0x04, 1, nil, "a")
+			Expect(client.response().status).Should(BeZero())
+			client.request(0x14, 2, nil, "a")
+			client.request(0x0a, 3, nil, "")
+			resp := client.response()
+			Expect(resp.opaque).Should(Equal(uint32(2)))
+			Expect(resp.status).Should(Equal(uint16(0x01)))
+			Expect(client.response().opaque).Should(Equal(uint32(3)))
+		})
+
+		It("clears the cache on flush", func() {
+			client.request(0x00, 0, nil, "a")
+			client.response()
+			client.request(0x08, 0, nil, "")
+			Expect(client.response().status).Should(BeZero())
+			client.request(0x00, 0, nil, "a")
+			client.response()
+			Expect(loaded()).Should(Equal([]string{"a", "a"}))
+		})
+
+		It("replies with each stat, then an empty one", func() {
+			client.request(0x00, 0, nil, "a")
+			client.response()
+			client.request(0x10, 0, nil, "")
+			stats := map[string]string{}
+			for {
+				resp := client.response()
+				if resp.key == "" {
+					break
+				}
+				stats[resp.key] = resp.value
+			}
+			Expect(stats).Should(HaveKeyWithValue("get_misses", "1"))
+			Expect(stats).Should(HaveKeyWithValue("curr_items", "1"))
+			Expect(stats).Should(HaveKeyWithValue("policy", "lru"))
+		})
+
+		It("refuses storage and unknown commands", func() {
+			client.request(0x01, 0, make([]byte, 8), "a")
+			Expect(client.response().status).Should(Equal(uint16(0x83)))
+			client.request(0x05, 0, nil, "a")
+			Expect(client.response().status).Should(Equal(uint16(0x81)))
+		})
+	})
+
+	It("serves text and binary clients at once", func() {
+		other := connect()
+		defer func() {
+			_ = other.conn.Close()
+		}()
+		client.write("get a\r\n")
+		client.lines("END")
+		other.request(0x00, 0, nil, "a")
+		Expect(other.response().value).Should(Equal("value of a"))
+		Expect(loaded()).Should(Equal([]string{"a"}))
+	})
+})
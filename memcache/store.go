@@ -0,0 +1,163 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wojnosystems/go-cache/internal/connpool"
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+var ErrStoreClosed = connpool.ErrClosed
+
+const (
+	// maxRelativeTTL is the longest expiry memcached takes as a number of seconds, longer ones must be a Unix time
+	maxRelativeTTL = 30 * 24 * time.Hour
+
+	// maxValue is the largest value accepted, the most memcached can be configured to store
+	maxValue = 1 << 30
+
+	// valueChunk is the most of a value allocated before its bytes arrive, so a size alone can't make the store
+	// allocate up to maxValue
+	valueChunk = 64 << 10
+)
+
+// Store keeps encoded values in a memcached server, for cache.NewRemote, using the text protocol. It keeps up to
+// maxIdle connections open between commands, and opens more when they are all in use
+type Store struct {
+	prefix string
+	conns  *connpool.Pool
+}
+
+// NewStore sends commands to the memcached server at addr, such as "10.0.0.1:11211".
+//
+// prefix: added to every key, so caches can share a server without their keys colliding. Keys, with the prefix,
+// must be valid memcached keys: up to 250 bytes, without spaces or control characters
+// maxIdle: how many connections to keep open between commands
+func NewStore(addr string, prefix string, maxIdle int) *Store {
+	return &Store{
+		prefix: prefix,
+		conns:  connpool.New(addr, maxIdle),
+	}
+}
+
+func (s *Store) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	key = s.prefix + key
+	if !validKey(key) {
+		return nil, false, fmt.Errorf("invalid memcached key '%s'", key)
+	}
+	err = s.conns.Do(ctx, func(c *connpool.Conn) (err error) {
+		_, _ = c.W.WriteString("get " + key + "\r\n")
+		if err = c.W.Flush(); err != nil {
+			return
+		}
+		for {
+			line, err := readReply(c.R)
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 4 || fields[0] != "VALUE" {
+				return fmt.Errorf("unexpected reply to get: %s", line)
+			}
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil || size < 0 || size > maxValue {
+				return fmt.Errorf("unexpected reply to get: %s", line)
+			}
+			var buf bytes.Buffer
+			if size+2 < valueChunk {
+				buf.Grow(int(size + 2))
+			} else {
+				buf.Grow(valueChunk)
+			}
+			if _, err = io.CopyN(&buf, c.R, size+2); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			value, ok = buf.Bytes()[:size], true
+		}
+	})
+	return
+}
+
+// Set the value, expiring it after ttl rounded up to the second, if it is positive
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	key = s.prefix + key
+	if !validKey(key) {
+		return fmt.Errorf("invalid memcached key '%s'", key)
+	}
+	return s.conns.Do(ctx, func(c *connpool.Conn) (err error) {
+		_, _ = c.W.WriteString("set " + key + " 0 " + exptime(ttl) + " " + strconv.Itoa(len(value)) + "\r\n")
+		_, _ = c.W.Write(value)
+		_, _ = c.W.WriteString("\r\n")
+		if err = c.W.Flush(); err != nil {
+			return
+		}
+		line, err := readReply(c.R)
+		if err == nil && line != "STORED" {
+			err = fmt.Errorf("unexpected reply to set: %s", line)
+		}
+		return
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	key = s.prefix + key
+	if !validKey(key) {
+		return fmt.Errorf("invalid memcached key '%s'", key)
+	}
+	return s.conns.Do(ctx, func(c *connpool.Conn) (err error) {
+		_, _ = c.W.WriteString("delete " + key + "\r\n")
+		if err = c.W.Flush(); err != nil {
+			return
+		}
+		line, err := readReply(c.R)
+		if err == nil && line != "DELETED" && line != "NOT_FOUND" {
+			err = fmt.Errorf("unexpected reply to delete: %s", line)
+		}
+		return
+	})
+}
+
+// exptime is ttl as memcached expects it: zero for none, seconds up to 30 days, and a Unix time after that
+func exptime(ttl time.Duration) string {
+	if ttl <= 0 {
+		return "0"
+	}
+	if ttl > maxRelativeTTL {
+		return strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	}
+	return strconv.FormatInt(int64((ttl+time.Second-1)/time.Second), 10)
+}
+
+// readReply reads a line of the reply, returning error replies as err
+func readReply(r *bufio.Reader) (line string, err error) {
+	raw, err := lineserver.ReadLine(r, maxLine)
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	line = string(raw)
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR ") || strings.HasPrefix(line, "SERVER_ERROR ") {
+		return "", fmt.Errorf("memcached replied: %s", line)
+	}
+	return
+}
+
+// Close the idle connections, and those in use once their commands finish
+func (s *Store) Close() error {
+	return s.conns.Close()
+}
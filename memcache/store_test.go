@@ -0,0 +1,207 @@
+package memcache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/internal/fakeserver"
+	"github.com/wojnosystems/go-cache/memcache"
+)
+
+// fakeMemcached is an in-memory memcached server understanding get, set and delete
+type fakeMemcached struct {
+	*fakeserver.Server
+
+	mu       sync.Mutex
+	values   map[string]string
+	exptimes map[string]string
+}
+
+func newFakeMemcached() *fakeMemcached {
+	f := &fakeMemcached{
+		values:   make(map[string]string),
+		exptimes: make(map[string]string),
+	}
+	var err error
+	f.Server, err = fakeserver.Start(f.serve)
+	Expect(err).ShouldNot(HaveOccurred())
+	return f
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		var reply string
+		f.mu.Lock()
+		switch fields[0] {
+		case "get":
+			for _, key := range fields[1:] {
+				if value, ok := f.values[key]; ok {
+					reply += "VALUE " + key + " 0 " + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+				}
+			}
+			reply += "END\r\n"
+		case "set":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err = io.ReadFull(r, data); err != nil {
+				f.mu.Unlock()
+				return
+			}
+			f.values[fields[1]] = string(data[:size])
+			f.exptimes[fields[1]] = fields[3]
+			reply = "STORED\r\n"
+		case "delete":
+			if _, ok := f.values[fields[1]]; ok {
+				delete(f.values, fields[1])
+				reply = "DELETED\r\n"
+			} else {
+				reply = "NOT_FOUND\r\n"
+			}
+		default:
+			reply = "ERROR\r\n"
+		}
+		f.mu.Unlock()
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+var _ = Describe("Store", func() {
+	var (
+		ctx     = context.Background()
+		server  *fakeMemcached
+		store   *memcache.Store
+		loads   int
+		subject cache.GetInvalidater
+	)
+	BeforeEach(func() {
+		server = newFakeMemcached()
+		store = memcache.NewStore(server.Addr(), "users:", 2)
+		loads = 0
+		subject = cache.NewRemote(store, cache.NewGobCodec(), 1500*time.Millisecond, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			loads++
+			return fmt.Sprintf("value of %v", key), nil
+		}, nil)
+	})
+	AfterEach(func() {
+		Expect(store.Close()).Should(Succeed())
+		Expect(server.Close()).Should(Succeed())
+	})
+
+	It("stores loaded values, with a prefix and expiry", func() {
+		Expect(subject.Get(ctx, "a")).Should(Equal("value of a"))
+		Expect(subject.Get(ctx, "a")).Should(Equal("value of a"))
+		Expect(loads).Should(Equal(1))
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		Expect(server.values).Should(HaveKey("users:a"))
+		Expect(server.exptimes).Should(HaveKeyWithValue("users:a", "2"))
+	})
+
+	It("deletes invalidated keys", func() {
+		_, _ = subject.Get(ctx, "a")
+		subject.Invalidate("a")
+		subject.Invalidate("a")
+		_, _ = subject.Get(ctx, "a")
+		Expect(loads).Should(Equal(2))
+	})
+
+	It("gets and sets binary values", func() {
+		value, ok, err := store.Get(ctx, "missing")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+		Expect(value).Should(BeNil())
+		Expect(store.Set(ctx, "b", []byte("binary\x00\r\nEND\r\n"), 0)).Should(Succeed())
+		value, ok, err = store.Get(ctx, "b")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(value).Should(Equal([]byte("binary\x00\r\nEND\r\n")))
+	})
+
+	It("sends long expiries as a Unix time", func() {
+		Expect(store.Set(ctx, "c", []byte("1"), 60*24*time.Hour)).Should(Succeed())
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		exptime, err := strconv.ParseInt(server.exptimes["users:c"], 10, 64)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(exptime).Should(BeNumerically("~", time.Now().Add(60*24*time.Hour).Unix(), 5))
+	})
+
+	It("refuses keys memcached would reject", func() {
+		_, _, err := store.Get(ctx, "has space")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("loads the key when the server is down", func() {
+		down := memcache.NewStore("127.0.0.1:1", "", 1)
+		subject = cache.NewRemote(down, cache.NewGobCodec(), 0, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return "loaded", nil
+		}, nil)
+		Expect(subject.Get(ctx, "a")).Should(Equal("loaded"))
+	})
+
+	It("does not allocate values before they arrive", func() {
+		replies := make(chan string, 1)
+		lying, err := fakeserver.Start(func(conn net.Conn) {
+			if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+				_, _ = conn.Write([]byte(<-replies))
+			}
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		defer func() {
+			_ = lying.Close()
+		}()
+		reader := memcache.NewStore(lying.Addr(), "", 1)
+		defer func() {
+			_ = reader.Close()
+		}()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		replies <- "VALUE a 0 1000000000\r\nshort"
+		_, _, err = reader.Get(ctx, "a")
+		Expect(err).Should(MatchError(io.ErrUnexpectedEOF))
+		runtime.ReadMemStats(&after)
+		Expect(after.TotalAlloc - before.TotalAlloc).Should(BeNumerically("<", 50<<20))
+		replies <- "VALUE a 0 2000000000\r\n"
+		_, _, err = reader.Get(ctx, "a")
+		Expect(err).Should(MatchError(ContainSubstring("unexpected reply to get")))
+	})
+
+	It("can be served by this module's own Server", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		own := memcache.NewServer(listener, cache.NewLRUByte(100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			return []byte("from the sidecar"), nil
+		}))
+		defer func() {
+			_ = own.Close()
+		}()
+		reader := memcache.NewStore(own.Addr().String(), "", 1)
+		defer func() {
+			_ = reader.Close()
+		}()
+		value, ok, err := reader.Get(ctx, "a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(value).Should(Equal([]byte("from the sidecar")))
+		Expect(reader.Set(ctx, "a", []byte("x"), 0)).Should(MatchError("memcached replied: SERVER_ERROR this cache is read only"))
+	})
+})
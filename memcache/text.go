@@ -0,0 +1,176 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+const (
+	// maxLine is the longest command line accepted, the same as memcached
+	maxLine = 2048
+
+	// maxKey is the longest key accepted, the same as memcached
+	maxKey = 250
+
+	// maxDiscard is the largest data block a refused storage command may send before the client is disconnected
+	maxDiscard = 1 << 20
+)
+
+// serveText answers commands in the text protocol
+func (s *Server) serveText(ctx context.Context, r *bufio.Reader, w *bufio.Writer) {
+	for {
+		line, err := lineserver.ReadLine(r, maxLine)
+		if err == lineserver.ErrLineTooLong {
+			_, _ = w.WriteString("CLIENT_ERROR line too long\r\n")
+			_ = w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			_, _ = w.WriteString("ERROR\r\n")
+		} else if !s.executeText(ctx, r, w, fields[0], fields[1:]) {
+			_ = w.Flush()
+			return
+		}
+		if !flush(r, w) {
+			return
+		}
+	}
+}
+
+// executeText runs the command and writes its reply, keepOpen is false if the client should be disconnected
+func (s *Server) executeText(ctx context.Context, r *bufio.Reader, w *bufio.Writer, name string, args []string) (keepOpen bool) {
+	switch name {
+	case "get", "gets":
+		s.textGet(ctx, w, args, name == "gets")
+	case "delete":
+		if len(args) == 0 || len(args) > 2 || !validKey(args[0]) {
+			_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		reply := s.delete(args[0])
+		if !noreply(args[1:]) {
+			_, _ = w.WriteString(reply + "\r\n")
+		}
+	case "flush_all":
+		if len(args) > 0 && args[0] != "noreply" && args[0] != "0" {
+			_, _ = w.WriteString("CLIENT_ERROR delayed flush_all is not supported\r\n")
+			return true
+		}
+		reply := "OK"
+		if clearer, ok := s.cache.(cache.Clearer); ok {
+			clearer.Clear()
+		} else {
+			reply = "SERVER_ERROR flush_all is not supported by this cache"
+		}
+		if !noreply(args) {
+			_, _ = w.WriteString(reply + "\r\n")
+		}
+	case "stats":
+		if len(args) > 0 {
+			_, _ = w.WriteString("ERROR\r\n")
+			return true
+		}
+		for _, stat := range s.stats() {
+			_, _ = w.WriteString("STAT " + stat[0] + " " + stat[1] + "\r\n")
+		}
+		_, _ = w.WriteString("END\r\n")
+	case "version":
+		_, _ = w.WriteString("VERSION " + version + "\r\n")
+	case "quit":
+		return false
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.refuseStorage(r, w, args)
+	default:
+		_, _ = w.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// textGet replies with each of the keys' values. If any of them fail to load, the reply is only the error
+func (s *Server) textGet(ctx context.Context, w *bufio.Writer, keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		_, _ = w.WriteString("ERROR\r\n")
+		return
+	}
+	var reply bytes.Buffer
+	for _, key := range keys {
+		if !validKey(key) {
+			_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+		value, err := s.cache.Get(ctx, key)
+		if err != nil {
+			_, _ = w.WriteString("SERVER_ERROR " + lineserver.FirstLine(err.Error()) + "\r\n")
+			return
+		}
+		reply.WriteString("VALUE " + key + " 0 " + strconv.Itoa(len(value)))
+		if withCAS {
+			reply.WriteString(" " + strconv.FormatUint(casOf(value), 10))
+		}
+		reply.WriteString("\r\n")
+		reply.Write(value)
+		reply.WriteString("\r\n")
+	}
+	_, _ = reply.WriteTo(w)
+	_, _ = w.WriteString("END\r\n")
+}
+
+// refuseStorage skips the data block of a storage command and refuses it, since the values only come from the
+// cache's ByteMapper
+func (s *Server) refuseStorage(r *bufio.Reader, w *bufio.Writer, args []string) (keepOpen bool) {
+	if len(args) < 4 {
+		_, _ = w.WriteString("ERROR\r\n")
+		return true
+	}
+	size, err := strconv.Atoi(args[3])
+	if err != nil || size < 0 || size > maxDiscard {
+		_, _ = w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	if _, err = io.CopyN(io.Discard, r, int64(size)+2); err != nil {
+		return false
+	}
+	if !noreply(args[4:]) {
+		_, _ = w.WriteString("SERVER_ERROR this cache is read only\r\n")
+	}
+	return true
+}
+
+// delete invalidates the key, replying whether it was cached
+func (s *Server) delete(key string) (reply string) {
+	reply = "DELETED"
+	if container, ok := s.cache.(cache.Container); ok && !container.Contains(key) {
+		reply = "NOT_FOUND"
+	}
+	s.cache.Invalidate(key)
+	return
+}
+
+// noreply is true if the last of the optional arguments asks for no reply
+func noreply(args []string) bool {
+	return len(args) > 0 && args[len(args)-1] == "noreply"
+}
+
+// validKey is true for keys memcached would accept: up to 250 bytes, without control characters or spaces
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
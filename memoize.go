@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// KeyEncoder derives the cache key for a memoized function's arguments, not counting its context.Context
+type KeyEncoder func(args []interface{}) (key interface{}, err error)
+
+// JSONKey encodes the arguments as a JSON array, for arguments that can't be keys themselves, such as slices, or
+// structs holding them. Unexported struct fields are not encoded, so arguments differing only in them share a key,
+// as do arguments of different types that encode the same, such as 1 and 1.0. Use a KeyEncoder of your own for those
+func JSONKey(args []interface{}) (key interface{}, err error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// hashProbe is looked up in to find out whether values can be map keys. It is never written to
+var hashProbe = map[interface{}]bool{struct{}{}: true}
+
+// hashable is true if the value can be a map key. Values of comparable types can't if they hold something that isn't,
+// such as a slice in an interface{} field
+func hashable(value interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = hashProbe[value]
+	return true
+}
+
+// defaultKey is the argument itself if there is exactly one and it can be a map key, an array of the arguments if
+// there are more and they all can be, and JSONKey otherwise
+func defaultKey(args []interface{}) (key interface{}, err error) {
+	if len(args) == 0 {
+		return struct{}{}, nil
+	}
+	for _, arg := range args {
+		if !hashable(arg) {
+			return JSONKey(args)
+		}
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	array := reflect.New(reflect.ArrayOf(len(args), interfaceType)).Elem()
+	for i := range args {
+		array.Index(i).Set(reflect.ValueOf(&args[i]).Elem())
+	}
+	return array.Interface(), nil
+}
+
+// MemoizePolicy controls how NewMemoized caches a function's results
+type MemoizePolicy struct {
+	// TTL is how long results are cached, zero caches them until they are invalidated or evicted
+	TTL time.Duration
+
+	// MaxItems is how many results are kept, least recently used first out. Zero keeps them all. Ignored if NewCache
+	// is set
+	MaxItems int
+
+	// NegativeTTL is how long errors are cached, so that a failing call isn't repeated for every caller. Zero never
+	// caches errors
+	NegativeTTL time.Duration
+
+	// IsNegative is true for errors worth caching, nil caches the errors IsTransient accepts
+	IsNegative ErrorClassifier
+
+	// KeyEncoder derives the key from the arguments. nil uses the arguments themselves when they all can be map keys,
+	// and JSONKey otherwise
+	KeyEncoder KeyEncoder
+
+	// NewCache builds the cache results are kept in, for policies other than NewUnbounded and NewLRUItem
+	NewCache LayerFactory
+}
+
+var errNoMemoArgs = fmt.Errorf("memoized functions only load through Call or Func")
+
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// memoArgsKey is the context key of the arguments the ValueMapper calls the function with
+type memoArgsKey struct{}
+
+// memoizedError is a cached error
+type memoizedError struct {
+	err error
+}
+
+// Memoized is a function whose results are cached, keyed on its arguments
+type Memoized struct {
+	fn     reflect.Value
+	policy MemoizePolicy
+	values GetInvalidater
+}
+
+// Memoize is NewMemoized(fn, policy).Func()
+func Memoize(fn interface{}, policy MemoizePolicy) interface{} {
+	return NewMemoized(fn, policy).Func()
+}
+
+// NewMemoized caches the results of fn, which must take a context.Context followed by any arguments, and return a
+// value and an error, such as func(ctx context.Context, id int) (*User, error). Concurrent calls with the same
+// arguments call fn once, with the first caller's context. Errors are returned to everyone waiting on that call,
+// and only cached if the policy has a NegativeTTL.
+//
+// It panics if fn does not have that form
+func NewMemoized(fn interface{}, policy MemoizePolicy) *Memoized {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() < 1 || t.In(0) != contextType || t.NumOut() != 2 || t.Out(1) != errorType {
+		panic(fmt.Sprintf("cache: Memoize needs a func(context.Context, ...) (R, error), not %s", t))
+	}
+	if policy.IsNegative == nil {
+		policy.IsNegative = IsTransient
+	}
+	if policy.KeyEncoder == nil {
+		policy.KeyEncoder = defaultKey
+	}
+	m := &Memoized{
+		fn:     v,
+		policy: policy,
+	}
+	switch {
+	case policy.NewCache != nil:
+		m.values = policy.NewCache(m.load)
+	case policy.MaxItems > 0:
+		m.values = NewLRUItem(policy.MaxItems, m.load)
+	default:
+		m.values = NewUnbounded(m.load)
+	}
+	return m
+}
+
+// Func is the memoized function, with the same type as the one given to NewMemoized, so type assert it back:
+//
+//	lookupUser := m.Func().(func(context.Context, int) (*User, error))
+func (m *Memoized) Func() interface{} {
+	t := m.fn.Type()
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		ctx, _ := in[0].Interface().(context.Context)
+		args := make([]interface{}, len(in)-1)
+		for i, arg := range in[1:] {
+			args[i] = arg.Interface()
+		}
+		value, err := m.Call(ctx, args...)
+		result := reflect.New(t.Out(0)).Elem()
+		if value != nil {
+			result.Set(reflect.ValueOf(value))
+		}
+		errResult := reflect.New(errorType).Elem()
+		if err != nil {
+			errResult.Set(reflect.ValueOf(err))
+		}
+		return []reflect.Value{result, errResult}
+	}).Interface()
+}
+
+// Call the memoized function with the arguments that follow its context.Context. The last argument of a variadic
+// function is its slice of variadic arguments
+func (m *Memoized) Call(ctx context.Context, args ...interface{}) (value interface{}, err error) {
+	in, err := m.in(args)
+	if err != nil {
+		return nil, err
+	}
+	key, err := m.policy.KeyEncoder(args)
+	if err != nil {
+		return nil, err
+	}
+	value, err = m.values.Get(context.WithValue(ctx, memoArgsKey{}, in), key)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := value.(memoizedError); ok {
+		return nil, cached.err
+	}
+	return value, nil
+}
+
+// Invalidate the result cached for the arguments
+func (m *Memoized) Invalidate(args ...interface{}) {
+	if key, err := m.policy.KeyEncoder(args); err == nil {
+		m.values.Invalidate(key)
+	}
+}
+
+// in is the arguments as the function takes them, after its context.Context
+func (m *Memoized) in(args []interface{}) (in []reflect.Value, err error) {
+	t := m.fn.Type()
+	if len(args) != t.NumIn()-1 {
+		return nil, fmt.Errorf("cache: memoized %s called with %d arguments", t, len(args))
+	}
+	in = make([]reflect.Value, len(args))
+	for i, arg := range args {
+		param := t.In(i + 1)
+		if arg == nil {
+			in[i] = reflect.Zero(param)
+			continue
+		}
+		in[i] = reflect.ValueOf(arg)
+		if !in[i].Type().AssignableTo(param) {
+			return nil, fmt.Errorf("cache: memoized %s called with %T for %s", t, arg, param)
+		}
+	}
+	return in, nil
+}
+
+// load is the ValueMapper, calling the function with the arguments in ctx
+func (m *Memoized) load(ctx context.Context, key interface{}) (value interface{}, err error) {
+	in, ok := ctx.Value(memoArgsKey{}).([]reflect.Value)
+	if !ok {
+		return nil, errNoMemoArgs
+	}
+	value, err = m.call(ctx, in)
+	switch {
+	case err == nil && m.policy.TTL > 0:
+		return WithTTL(value, m.policy.TTL), nil
+	case err == nil:
+		return value, nil
+	case m.policy.NegativeTTL > 0 && m.policy.IsNegative(err):
+		return WithTTL(memoizedError{err: err}, m.policy.NegativeTTL), nil
+	}
+	return nil, err
+}
+
+func (m *Memoized) call(ctx context.Context, args []reflect.Value) (value interface{}, err error) {
+	in := append([]reflect.Value{reflect.ValueOf(&ctx).Elem()}, args...)
+	var out []reflect.Value
+	if m.fn.Type().IsVariadic() {
+		out = m.fn.CallSlice(in)
+	} else {
+		out = m.fn.Call(in)
+	}
+	if !out[1].IsNil() {
+		err = out[1].Interface().(error)
+	}
+	return out[0].Interface(), err
+}
@@ -0,0 +1,223 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+)
+
+type memoQuery struct {
+	Table string
+	IDs   []int
+}
+
+type memoFilter struct {
+	min, max int
+}
+
+type memoBox struct {
+	V interface{}
+}
+
+var _ = Describe("Memoize", func() {
+	var (
+		calls *int32
+		fail  error
+	)
+	BeforeEach(func() {
+		calls = new(int32)
+		fail = nil
+	})
+	square := func(ctx context.Context, n int) (int, error) {
+		atomic.AddInt32(calls, 1)
+		return n * n, fail
+	}
+
+	It("calls the function once for each argument", func() {
+		subject := cache.Memoize(square, cache.MemoizePolicy{}).(func(context.Context, int) (int, error))
+		Expect(subject(ignoreCtx, 3)).Should(Equal(9))
+		Expect(subject(ignoreCtx, 3)).Should(Equal(9))
+		Expect(subject(ignoreCtx, 4)).Should(Equal(16))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("derives keys from several arguments, including ones that can't be map keys", func() {
+		subject := cache.Memoize(func(ctx context.Context, q memoQuery, sep string) (string, error) {
+			atomic.AddInt32(calls, 1)
+			return fmt.Sprint(q.Table, sep, q.IDs), nil
+		}, cache.MemoizePolicy{}).(func(context.Context, memoQuery, string) (string, error))
+		Expect(subject(ignoreCtx, memoQuery{Table: "users", IDs: []int{1, 2}}, ":")).Should(Equal("users:[1 2]"))
+		Expect(subject(ignoreCtx, memoQuery{Table: "users", IDs: []int{1, 2}}, ":")).Should(Equal("users:[1 2]"))
+		Expect(subject(ignoreCtx, memoQuery{Table: "users", IDs: []int{1, 3}}, ":")).Should(Equal("users:[1 3]"))
+		Expect(subject(ignoreCtx, memoQuery{Table: "users", IDs: []int{1, 2}}, "/")).Should(Equal("users/[1 2]"))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(3)))
+	})
+
+	It("tells apart arguments that differ only in unexported fields", func() {
+		subject := cache.Memoize(func(ctx context.Context, f memoFilter, table string) (string, error) {
+			atomic.AddInt32(calls, 1)
+			return fmt.Sprint(table, f.min, f.max), nil
+		}, cache.MemoizePolicy{}).(func(context.Context, memoFilter, string) (string, error))
+		Expect(subject(ignoreCtx, memoFilter{min: 1, max: 2}, "users")).Should(Equal("users1 2"))
+		Expect(subject(ignoreCtx, memoFilter{min: 3, max: 4}, "users")).Should(Equal("users3 4"))
+		Expect(subject(ignoreCtx, memoFilter{min: 1, max: 2}, "users")).Should(Equal("users1 2"))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("derives keys from comparable arguments holding values that can't be map keys", func() {
+		subject := cache.Memoize(func(ctx context.Context, box memoBox) (string, error) {
+			atomic.AddInt32(calls, 1)
+			return fmt.Sprint(box.V), nil
+		}, cache.MemoizePolicy{}).(func(context.Context, memoBox) (string, error))
+		Expect(subject(ignoreCtx, memoBox{V: []int{1, 2}})).Should(Equal("[1 2]"))
+		Expect(subject(ignoreCtx, memoBox{V: []int{1, 2}})).Should(Equal("[1 2]"))
+		Expect(subject(ignoreCtx, memoBox{V: 3})).Should(Equal("3"))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("memoizes variadic functions", func() {
+		subject := cache.Memoize(func(ctx context.Context, words ...string) (string, error) {
+			atomic.AddInt32(calls, 1)
+			return strings.Join(words, " "), nil
+		}, cache.MemoizePolicy{}).(func(context.Context, ...string) (string, error))
+		Expect(subject(ignoreCtx, "a", "b")).Should(Equal("a b"))
+		Expect(subject(ignoreCtx, "a", "b")).Should(Equal("a b"))
+		Expect(subject(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("uses the KeyEncoder", func() {
+		subject := cache.Memoize(square, cache.MemoizePolicy{
+			KeyEncoder: func(args []interface{}) (interface{}, error) {
+				return args[0].(int) % 2, nil
+			},
+		}).(func(context.Context, int) (int, error))
+		Expect(subject(ignoreCtx, 3)).Should(Equal(9))
+		Expect(subject(ignoreCtx, 5)).Should(Equal(9))
+	})
+
+	It("calls the function again once results expire", func() {
+		subject := cache.Memoize(square, cache.MemoizePolicy{TTL: 20 * time.Millisecond}).(func(context.Context, int) (int, error))
+		_, _ = subject(ignoreCtx, 3)
+		_, _ = subject(ignoreCtx, 3)
+		time.Sleep(30 * time.Millisecond)
+		_, _ = subject(ignoreCtx, 3)
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("keeps at most MaxItems results", func() {
+		subject := cache.Memoize(square, cache.MemoizePolicy{MaxItems: 2}).(func(context.Context, int) (int, error))
+		for _, n := range []int{1, 2, 3, 1} {
+			_, _ = subject(ignoreCtx, n)
+		}
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(4)))
+	})
+
+	It("builds the cache with NewCache", func() {
+		var built int
+		subject := cache.NewMemoized(square, cache.MemoizePolicy{
+			MaxItems: 1,
+			NewCache: func(valueMapper cache.ValueMapper) cache.GetInvalidater {
+				built++
+				return cache.NewUnbounded(valueMapper)
+			},
+		})
+		for _, n := range []int{1, 2, 1} {
+			_, _ = subject.Call(ignoreCtx, n)
+		}
+		Expect(built).Should(Equal(1))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("does not cache errors without a NegativeTTL", func() {
+		fail = fmt.Errorf("not found")
+		subject := cache.Memoize(square, cache.MemoizePolicy{}).(func(context.Context, int) (int, error))
+		_, err := subject(ignoreCtx, 3)
+		Expect(err).Should(MatchError("not found"))
+		_, _ = subject(ignoreCtx, 3)
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("caches errors for NegativeTTL", func() {
+		fail = fmt.Errorf("not found")
+		subject := cache.Memoize(square, cache.MemoizePolicy{NegativeTTL: 20 * time.Millisecond}).(func(context.Context, int) (int, error))
+		_, err := subject(ignoreCtx, 3)
+		Expect(err).Should(MatchError("not found"))
+		value, err := subject(ignoreCtx, 3)
+		Expect(err).Should(MatchError("not found"))
+		Expect(value).Should(BeZero())
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(1)))
+		time.Sleep(30 * time.Millisecond)
+		fail = nil
+		Expect(subject(ignoreCtx, 3)).Should(Equal(9))
+	})
+
+	It("only caches errors IsNegative accepts", func() {
+		fail = context.DeadlineExceeded
+		subject := cache.Memoize(square, cache.MemoizePolicy{NegativeTTL: time.Minute}).(func(context.Context, int) (int, error))
+		_, _ = subject(ignoreCtx, 3)
+		_, _ = subject(ignoreCtx, 3)
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("calls the function once for concurrent calls with the same arguments", func() {
+		release := make(chan struct{})
+		subject := cache.Memoize(func(ctx context.Context, n int) (int, error) {
+			<-release
+			return square(ctx, n)
+		}, cache.MemoizePolicy{}).(func(context.Context, int) (int, error))
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(subject(ignoreCtx, 3)).Should(Equal(9))
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(1)))
+	})
+
+	It("invalidates the result for the arguments", func() {
+		subject := cache.NewMemoized(square, cache.MemoizePolicy{})
+		Expect(subject.Call(ignoreCtx, 3)).Should(Equal(9))
+		subject.Invalidate(3)
+		Expect(subject.Call(ignoreCtx, 3)).Should(Equal(9))
+		Expect(atomic.LoadInt32(calls)).Should(Equal(int32(2)))
+	})
+
+	It("returns pointers and nil results", func() {
+		subject := cache.Memoize(func(ctx context.Context, name string) (*memoQuery, error) {
+			if name == "" {
+				return nil, nil
+			}
+			return &memoQuery{Table: name}, nil
+		}, cache.MemoizePolicy{}).(func(context.Context, string) (*memoQuery, error))
+		Expect(subject(ignoreCtx, "users")).Should(Equal(&memoQuery{Table: "users"}))
+		Expect(subject(ignoreCtx, "")).Should(BeNil())
+	})
+
+	It("rejects arguments of the wrong type", func() {
+		subject := cache.NewMemoized(square, cache.MemoizePolicy{})
+		_, err := subject.Call(ignoreCtx, "3")
+		Expect(err).Should(HaveOccurred())
+		_, err = subject.Call(ignoreCtx, 3, 4)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("panics for functions it can't memoize", func() {
+		Expect(func() {
+			cache.Memoize(func(n int) int { return n }, cache.MemoizePolicy{})
+		}).Should(Panic())
+	})
+})
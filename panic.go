@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// LoaderPanicError is returned in place of a panic from a ValueMapper, BulkValueMapper or ValueSizer,
+// so the panic can't unwind through the cache and leave it, or the callers waiting on the load, stuck
+type LoaderPanicError struct {
+	// Value is what was passed to panic
+	Value interface{}
+
+	// Stack is where the panic happened
+	Stack []byte
+}
+
+func (e *LoaderPanicError) Error() string {
+	return fmt.Sprintf("loader panicked: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it was an error
+func (e *LoaderPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// recoverLoaderPanic turns a panic into a LoaderPanicError. It must be deferred
+func recoverLoaderPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &LoaderPanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+func safeLoad(ctx context.Context, valueMapper ValueMapper, key interface{}) (value interface{}, err error) {
+	defer recoverLoaderPanic(&err)
+	return valueMapper(ctx, key)
+}
+
+func safeBulkLoad(ctx context.Context, bulkMapper BulkValueMapper, keys []interface{}) (values map[interface{}]interface{}, err error) {
+	defer recoverLoaderPanic(&err)
+	return bulkMapper(ctx, keys)
+}
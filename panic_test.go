@@ -0,0 +1,145 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"sync"
+	"time"
+)
+
+var _ = Describe("Panics", func() {
+	var (
+		panicErr *cache.LoaderPanicError
+	)
+
+	When("the ValueMapper panics", func() {
+		var (
+			release chan struct{}
+			subject cache.GetInvalidater
+		)
+		BeforeEach(func() {
+			release = make(chan struct{})
+			release := release
+			subject = cache.NewLRUItem(10, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				<-release
+				panic(intentionalErr)
+			})
+		})
+		It("returns a LoaderPanicError", func() {
+			close(release)
+			_, err := subject.Get(ignoreCtx, "1")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+			Expect(panicErr.Value).Should(Equal(intentionalErr))
+			Expect(string(panicErr.Stack)).Should(ContainSubstring("panic_test.go"))
+			Expect(err).Should(MatchError(intentionalErr))
+		})
+		It("fails every caller waiting on the load", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := subject.Get(ignoreCtx, "1")
+					var ownPanicErr *cache.LoaderPanicError
+					Expect(errors.As(err, &ownPanicErr)).Should(BeTrue())
+				}()
+			}
+			Eventually(func() uint64 {
+				return subject.(cache.Inspector).Stats().Misses
+			}).Should(Equal(uint64(5)))
+			close(release)
+			wg.Wait()
+		})
+		It("leaves nothing behind", func() {
+			close(release)
+			_, _ = subject.Get(ignoreCtx, "1")
+			used, _ := subject.(cache.Inspector).Usage()
+			Expect(used).Should(BeZero())
+			Expect(subject.(cache.Inspector).RecentKeys(10)).Should(BeEmpty())
+		})
+	})
+
+	When("the ValueSizer panics", func() {
+		var (
+			subject cache.GetInvalidater
+		)
+		BeforeEach(func() {
+			subject = cache.NewLRU(10, func(value interface{}) uint {
+				if value == "bad" {
+					panic("cannot size")
+				}
+				return 1
+			}, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				return key, nil
+			})
+			_, _ = subject.Get(ignoreCtx, "good")
+		})
+		It("does not cache the value", func() {
+			_, err := subject.Get(ignoreCtx, "bad")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+			Expect(panicErr.Value).Should(Equal("cannot size"))
+			used, _ := subject.(cache.Inspector).Usage()
+			Expect(used).Should(Equal(uint(1)))
+			Expect(subject.(cache.Inspector).RecentKeys(10)).Should(Equal([]interface{}{"good"}))
+		})
+		It("does not leave the value's expiry behind for the next value", func() {
+			loads := 0
+			subject = cache.NewLRU(10, func(value interface{}) uint {
+				if value == "bad" {
+					panic("cannot size")
+				}
+				return 1
+			}, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				loads++
+				if loads == 1 {
+					return cache.WithTTL("bad", 20*time.Millisecond), nil
+				}
+				return "good", nil
+			})
+			_, err := subject.Get(ignoreCtx, "1")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+			Expect(subject.Get(ignoreCtx, "1")).Should(Equal("good"))
+			time.Sleep(30 * time.Millisecond)
+			Expect(subject.Get(ignoreCtx, "1")).Should(Equal("good"))
+			Expect(loads).Should(Equal(2))
+		})
+	})
+
+	When("the BulkValueMapper panics", func() {
+		It("fails every key in the call", func() {
+			subject := cache.NewUnboundedBulk(func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+				panic("bulk")
+			})
+			_, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2"})
+			var keyErrs cache.KeyErrors
+			Expect(errors.As(err, &keyErrs)).Should(BeTrue())
+			Expect(keyErrs).Should(HaveLen(2))
+			Expect(errors.As(keyErrs["1"], &panicErr)).Should(BeTrue())
+		})
+		It("fails every caller in the batch", func() {
+			subject := cache.NewBatchingMapper(func(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+				panic("batch")
+			}, time.Millisecond, 0)
+			_, err := subject(ignoreCtx, "1")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+		})
+	})
+
+	When("a circuit breaker trial panics", func() {
+		It("counts as a failure", func() {
+			subject := cache.NewCircuitBreakerMapper(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				panic("trial")
+			}, cache.BreakerPolicy{
+				FailureThreshold: 1,
+			})
+			_, err := subject(ignoreCtx, "1")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+			_, err = subject(ignoreCtx, "1")
+			Expect(errors.As(err, &panicErr)).Should(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,99 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/wojnosystems/go-cache/hashring"
+)
+
+// defaultReplicas is how many virtual nodes each peer has on the ring
+const defaultReplicas = 100
+
+// HTTPPool picks peers by consistent hashing over their base URLs, and fetches from them over HTTP
+type HTTPPool struct {
+	self     string
+	basePath string
+	client   *http.Client
+
+	mu   sync.RWMutex
+	ring *hashring.Ring
+}
+
+// NewHTTPPool creates a pool for the node serving at self, such as "http://10.0.0.1:8080".
+//
+// basePath: where every peer's Node is mounted, such as "/_cache/users"
+// client: makes the requests to peers, http.DefaultClient if nil
+func NewHTTPPool(self string, basePath string, client *http.Client) *HTTPPool {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPPool{
+		self:     self,
+		basePath: basePath,
+		client:   client,
+		ring:     hashring.New(defaultReplicas),
+	}
+}
+
+// Set replaces the peers, which should include self. Every node must be given the same peers, or they will
+// disagree on who owns which keys, and load them more than once
+func (p *HTTPPool) Set(peers ...string) {
+	r := hashring.New(defaultReplicas)
+	for _, peer := range peers {
+		r.Add(peer, 1)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ring = r
+}
+
+func (p *HTTPPool) PickPeer(key string) (peer Peer, ok bool) {
+	p.mu.RLock()
+	owner, ok := p.ring.Get(key)
+	p.mu.RUnlock()
+	if !ok || owner == p.self {
+		return nil, false
+	}
+	return &httpPeer{
+		baseURL: owner + p.basePath,
+		client:  p.client,
+	}, true
+}
+
+type httpPeer struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (h *httpPeer) Fetch(ctx context.Context, key string) (value []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+"?key="+url.QueryEscape(key), nil)
+	if err != nil {
+		return
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusInternalServerError:
+		// the Node serving the key failed to load it
+		return nil, &PeerError{Peer: h.baseURL, Message: strings.TrimSpace(string(body))}
+	default:
+		return nil, fmt.Errorf("peer %s returned %s: %s", h.baseURL, resp.Status, body)
+	}
+	return body, nil
+}
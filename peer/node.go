@@ -0,0 +1,133 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+var ErrKeyNotString = fmt.Errorf("peer caches only support string keys")
+
+// Peer is another node that owns some of the keys
+type Peer interface {
+	// Fetch the encoded value for key from the peer, which loads it if it does not have it cached. Errors the peer
+	// answers with, such as its own load failing, must be returned as a *PeerError
+	Fetch(ctx context.Context, key string) (value []byte, err error)
+}
+
+// PeerError is an error the owner of a key answered a fetch with, such as its load failing. Nodes pass these on to
+// their callers, rather than loading the key themselves
+type PeerError struct {
+	Peer    string
+	Message string
+}
+
+func (e *PeerError) Error() string {
+	return fmt.Sprintf("peer %s failed: %s", e.Peer, e.Message)
+}
+
+// PeerPicker decides which node owns each key
+type PeerPicker interface {
+	// PickPeer returns the peer that owns key, ok is false if this node owns it
+	PickPeer(key string) (peer Peer, ok bool)
+}
+
+// Node is one member of a cache spread across many processes. Each key is owned by one node, which is the only one
+// to call the ValueMapper for it, so every key is loaded once for the whole group rather than once per process.
+// Misses for keys owned by other nodes are fetched from the owner, and mirrored in a small hot cache, so the most
+// popular keys don't all land on their owner.
+//
+// Node is an http.Handler, which serves the keys it owns to its peers. Mount it at the basePath given to the
+// HTTPPool. Only string keys are supported
+type Node struct {
+	picker PeerPicker
+	codec  cache.ValueCodec
+	owned  cache.GetInvalidater
+	hot    cache.GetInvalidater
+}
+
+// NewNode creates a node.
+//
+// picker: chooses the owner of each key, such as an HTTPPool
+// codec: encodes values to send them to peers, every node must use the same one
+// newOwned: builds the cache of the keys this node owns, which may be large
+// newHot: builds the cache of keys owned by other nodes, which should be small
+// hotTTL: if positive, how long mirrored values are kept before they are fetched from their owner again
+// valueMapper: loads the keys this node owns
+func NewNode(picker PeerPicker, codec cache.ValueCodec, newOwned, newHot cache.LayerFactory, hotTTL time.Duration, valueMapper cache.ValueMapper) *Node {
+	n := &Node{
+		picker: picker,
+		codec:  codec,
+	}
+	n.owned = newOwned(valueMapper)
+	n.hot = newHot(n.fetching(hotTTL, valueMapper))
+	return n
+}
+
+// fetching is the hot cache's ValueMapper. If the owner can't be reached, the value is loaded here instead. Errors
+// the owner answers with are returned, so a key that fails to load is not loaded again here
+func (n *Node) fetching(hotTTL time.Duration, valueMapper cache.ValueMapper) cache.ValueMapper {
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		peer, ok := n.picker.PickPeer(key.(string))
+		if !ok {
+			return n.owned.Get(ctx, key)
+		}
+		data, err := peer.Fetch(ctx, key.(string))
+		var peerErr *PeerError
+		switch {
+		case err == nil:
+			if value, err = n.codec.Decode(data); err != nil {
+				return nil, err
+			}
+		case errors.As(err, &peerErr) || ctx.Err() != nil:
+			return nil, err
+		default:
+			if value, err = valueMapper(ctx, key); err != nil {
+				return
+			}
+		}
+		if hotTTL > 0 {
+			value = cache.WithTTL(value, hotTTL)
+		}
+		return
+	}
+}
+
+// Get the value from this node if it owns the key, otherwise from the hot cache, or the owner
+func (n *Node) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	s, ok := key.(string)
+	if !ok {
+		return nil, ErrKeyNotString
+	}
+	if _, remote := n.picker.PickPeer(s); remote {
+		return n.hot.Get(ctx, key)
+	}
+	return n.owned.Get(ctx, key)
+}
+
+// Invalidate the key on this node. Other nodes keep their copies, use a bus.Bus to invalidate them too
+func (n *Node) Invalidate(key interface{}) {
+	n.hot.Invalidate(key)
+	n.owned.Invalidate(key)
+}
+
+// ServeHTTP serves a peer's request for a key. Keys are loaded here even if this node does not think it owns them,
+// since the peers may briefly disagree on who does while they are being changed
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	value, err := n.owned.Get(r.Context(), key)
+	var data []byte
+	if err == nil {
+		data, err = n.codec.Encode(value)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(data)
+}
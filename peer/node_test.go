@@ -0,0 +1,200 @@
+package peer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/peer"
+)
+
+const basePath = "/_cache/test"
+
+// cluster is a group of nodes, each served by its own httptest.Server
+type cluster struct {
+	servers []*httptest.Server
+	nodes   []*peer.Node
+
+	mu       sync.Mutex
+	loads    map[string][]int
+	requests []int64
+}
+
+func newCluster(size int, hotTTL time.Duration) *cluster {
+	c := &cluster{
+		loads:    make(map[string][]int),
+		requests: make([]int64, size),
+	}
+	handlers := make([]http.Handler, size)
+	var urls []string
+	for i := 0; i < size; i++ {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&c.requests[i], 1)
+			handlers[i].ServeHTTP(w, r)
+		}))
+		c.servers = append(c.servers, server)
+		urls = append(urls, server.URL)
+	}
+	newCache := func(valueMapper cache.ValueMapper) cache.GetInvalidater {
+		return cache.NewLRUItem(100, valueMapper)
+	}
+	for i, url := range urls {
+		i := i
+		pool := peer.NewHTTPPool(url, basePath, nil)
+		pool.Set(urls...)
+		node := peer.NewNode(pool, cache.NewGobCodec(), newCache, newCache, hotTTL, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			c.mu.Lock()
+			c.loads[key.(string)] = append(c.loads[key.(string)], i)
+			c.mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			if strings.HasPrefix(key.(string), "failing") {
+				return nil, errors.New("can't load " + key.(string))
+			}
+			return "value of " + key.(string), nil
+		})
+		mux := http.NewServeMux()
+		mux.Handle(basePath, node)
+		handlers[i] = mux
+		c.nodes = append(c.nodes, node)
+	}
+	return c
+}
+
+func (c *cluster) close() {
+	for _, server := range c.servers {
+		server.Close()
+	}
+}
+
+func (c *cluster) loadsOf(key string) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int(nil), c.loads[key]...)
+}
+
+func (c *cluster) totalRequests() (total int64) {
+	for i := range c.requests {
+		total += atomic.LoadInt64(&c.requests[i])
+	}
+	return
+}
+
+var _ = Describe("Node", func() {
+	var (
+		ctx     context.Context
+		subject *cluster
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		subject = newCluster(3, 0)
+	})
+	AfterEach(func() {
+		subject.close()
+	})
+
+	It("loads each key once, on its owner", func() {
+		for k := 0; k < 20; k++ {
+			key := fmt.Sprintf("key%d", k)
+			for _, node := range subject.nodes {
+				Expect(node.Get(ctx, key)).Should(Equal("value of " + key))
+			}
+			Expect(subject.loadsOf(key)).Should(HaveLen(1))
+		}
+	})
+
+	It("spreads the keys across the nodes", func() {
+		owners := map[int]bool{}
+		for k := 0; k < 30; k++ {
+			key := fmt.Sprintf("key%d", k)
+			_, _ = subject.nodes[0].Get(ctx, key)
+			owners[subject.loadsOf(key)[0]] = true
+		}
+		Expect(owners).Should(HaveLen(3))
+	})
+
+	It("loads a key once when every node asks for it at the same time", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			for _, node := range subject.nodes {
+				wg.Add(1)
+				go func(node *peer.Node) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(node.Get(ctx, "popular")).Should(Equal("value of popular"))
+				}(node)
+			}
+		}
+		wg.Wait()
+		Expect(subject.loadsOf("popular")).Should(HaveLen(1))
+		Expect(subject.totalRequests()).Should(BeNumerically("<=", 2))
+	})
+
+	It("mirrors keys owned by other nodes", func() {
+		for _, node := range subject.nodes {
+			_, _ = node.Get(ctx, "hot")
+			_, _ = node.Get(ctx, "hot")
+		}
+		Expect(subject.totalRequests()).Should(Equal(int64(2)))
+	})
+
+	It("fetches mirrored keys again once the hot TTL passes", func() {
+		subject.close()
+		subject = newCluster(3, 20*time.Millisecond)
+		for _, node := range subject.nodes {
+			_, _ = node.Get(ctx, "hot")
+		}
+		time.Sleep(30 * time.Millisecond)
+		for _, node := range subject.nodes {
+			_, _ = node.Get(ctx, "hot")
+		}
+		Expect(subject.totalRequests()).Should(Equal(int64(4)))
+		Expect(subject.loadsOf("hot")).Should(HaveLen(1))
+	})
+
+	It("loads the key itself when the owner can't be reached", func() {
+		_, _ = subject.nodes[0].Get(ctx, "key1")
+		owner := subject.loadsOf("key1")
+		Expect(owner).Should(HaveLen(1))
+		subject.servers[owner[0]].Close()
+		other := (owner[0] + 1) % 3
+		subject.nodes[other].Invalidate("key1")
+		Expect(subject.nodes[other].Get(ctx, "key1")).Should(Equal("value of key1"))
+		Expect(subject.loadsOf("key1")).Should(ConsistOf(owner[0], other))
+	})
+
+	It("passes on the owner's load errors instead of loading the key itself", func() {
+		_, err := subject.nodes[0].Get(ctx, "failing")
+		Expect(err).Should(HaveOccurred())
+		Expect(subject.loadsOf("failing")).Should(HaveLen(1))
+		owner := subject.loadsOf("failing")[0]
+		other := (owner + 1) % 3
+		_, err = subject.nodes[other].Get(ctx, "failing")
+		var peerErr *peer.PeerError
+		Expect(errors.As(err, &peerErr)).Should(BeTrue())
+		Expect(peerErr.Message).Should(ContainSubstring("can't load failing"))
+		Expect(subject.loadsOf("failing")).Should(Equal([]int{owner, owner}))
+	})
+
+	It("invalidates the node's own copy", func() {
+		_, _ = subject.nodes[0].Get(ctx, "key2")
+		owner := subject.loadsOf("key2")[0]
+		subject.nodes[owner].Invalidate("key2")
+		_, _ = subject.nodes[owner].Get(ctx, "key2")
+		Expect(subject.loadsOf("key2")).Should(Equal([]int{owner, owner}))
+	})
+
+	It("refuses keys that are not strings", func() {
+		_, err := subject.nodes[0].Get(ctx, 1)
+		Expect(err).Should(MatchError(peer.ErrKeyNotString))
+	})
+})
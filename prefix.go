@@ -0,0 +1,172 @@
+package cache
+
+import "strings"
+
+// PredicateInvalidater drops every key matching a predicate
+type PredicateInvalidater interface {
+	// InvalidateWhere invalidates every cached or loading key for which matches returns true.
+	// matches is called with the cache locked, so it must be quick and must not use the cache
+	InvalidateWhere(matches func(key interface{}) bool)
+}
+
+// PrefixInvalidater drops every string key starting with a prefix
+type PrefixInvalidater interface {
+	// InvalidatePrefix invalidates every cached or loading key of type string that starts with prefix.
+	// Keys of any other type, including types whose underlying type is string, are never matched
+	InvalidatePrefix(prefix string)
+
+	// IndexPrefixes keeps an index of the cached string keys from now on, so InvalidatePrefix only visits the keys
+	// it removes instead of scanning the whole cache. The index costs memory and a little time on every store and
+	// remove, so only enable it for large caches that invalidate by prefix often
+	IndexPrefixes()
+}
+
+func (u *unbounded) InvalidateWhere(matches func(key interface{}) bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.invalidateWhereLocked(matches)
+}
+
+func (u *unbounded) invalidateWhereLocked(matches func(key interface{}) bool) {
+	for key := range u.inflight {
+		if matches(key) {
+			u.invalidateLocked(key)
+		}
+	}
+	for key := range u.cache {
+		if matches(key) {
+			u.invalidateLocked(key)
+		}
+	}
+}
+
+func (u *unbounded) InvalidatePrefix(prefix string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.invalidatePrefixLocked(prefix)
+}
+
+func (u *unbounded) invalidatePrefixLocked(prefix string) {
+	hasPrefix := func(key interface{}) bool {
+		s, ok := key.(string)
+		return ok && strings.HasPrefix(s, prefix)
+	}
+	if u.prefixes == nil {
+		u.invalidateWhereLocked(hasPrefix)
+		return
+	}
+	for key := range u.inflight {
+		if hasPrefix(key) {
+			u.invalidateLocked(key)
+		}
+	}
+	for _, key := range u.prefixes.withPrefix(prefix) {
+		u.invalidateLocked(key)
+	}
+}
+
+func (u *unbounded) IndexPrefixes() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.prefixes != nil {
+		return
+	}
+	u.prefixes = &radixNode{}
+	for key := range u.cache {
+		if s, ok := key.(string); ok {
+			u.prefixes.insert(s)
+		}
+	}
+}
+
+// radixNode is a radix tree of strings. Each node's label is the part of the key after its parent's label
+type radixNode struct {
+	label    string
+	isKey    bool
+	children map[byte]*radixNode
+}
+
+func (n *radixNode) insert(key string) {
+	for {
+		if key == "" {
+			n.isKey = true
+			return
+		}
+		if n.children == nil {
+			n.children = make(map[byte]*radixNode)
+		}
+		child, ok := n.children[key[0]]
+		if !ok {
+			n.children[key[0]] = &radixNode{label: key, isKey: true}
+			return
+		}
+		common := commonPrefixLen(child.label, key)
+		if common < len(child.label) {
+			// split the child so the shared part of the labels gets its own node
+			split := &radixNode{
+				label:    child.label[:common],
+				children: map[byte]*radixNode{child.label[common]: child},
+			}
+			child.label = child.label[common:]
+			n.children[key[0]] = split
+			child = split
+		}
+		n, key = child, key[common:]
+	}
+}
+
+// remove the key, merging nodes left with a single child back together. Returns whether n is now empty
+func (n *radixNode) remove(key string) (empty bool) {
+	if key == "" {
+		n.isKey = false
+	} else if child, ok := n.children[key[0]]; ok && strings.HasPrefix(key, child.label) {
+		if child.remove(key[len(child.label):]) {
+			delete(n.children, key[0])
+		} else if !child.isKey && len(child.children) == 1 {
+			for _, grandchild := range child.children {
+				grandchild.label = child.label + grandchild.label
+				n.children[key[0]] = grandchild
+			}
+		}
+	}
+	return !n.isKey && len(n.children) == 0
+}
+
+// withPrefix returns every key starting with prefix
+func (n *radixNode) withPrefix(prefix string) (keys []interface{}) {
+	path := ""
+	for prefix != "" {
+		child, ok := n.children[prefix[0]]
+		if !ok {
+			return
+		}
+		switch {
+		case strings.HasPrefix(prefix, child.label):
+			prefix = prefix[len(child.label):]
+		case strings.HasPrefix(child.label, prefix):
+			prefix = ""
+		default:
+			return
+		}
+		path += child.label
+		n = child
+	}
+	n.collect(path, &keys)
+	return
+}
+
+func (n *radixNode) collect(path string, keys *[]interface{}) {
+	if n.isKey {
+		*keys = append(*keys, path)
+	}
+	for _, child := range n.children {
+		child.collect(path+child.label, keys)
+	}
+}
+
+func commonPrefixLen(a, b string) (i int) {
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return
+}
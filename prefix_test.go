@@ -0,0 +1,167 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+func sortedCachedKeys(subject interface{}) (keys []string) {
+	for _, key := range subject.(cache.Inspector).RecentKeys(10_000) {
+		keys = append(keys, fmt.Sprint(key))
+	}
+	sort.Strings(keys)
+	return
+}
+
+var _ = Describe("Prefix and predicate invalidation", func() {
+	var (
+		subject cache.GetInvalidater
+	)
+	BeforeEach(func() {
+		subject = cache.NewLRUItem(5, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return key, nil
+		})
+		for _, key := range []interface{}{"tenant:1:a", "tenant:1:b", "tenant:10:a", "tenant:2:a", 1} {
+			_, _ = subject.Get(ignoreCtx, key)
+		}
+	})
+
+	It("invalidates keys matching a predicate", func() {
+		subject.(cache.PredicateInvalidater).InvalidateWhere(func(key interface{}) bool {
+			s, ok := key.(string)
+			return ok && strings.HasSuffix(s, ":a")
+		})
+		Expect(sortedCachedKeys(subject)).Should(Equal([]string{"1", "tenant:1:b"}))
+	})
+
+	It("releases the capacity of invalidated keys", func() {
+		subject.(cache.PredicateInvalidater).InvalidateWhere(func(key interface{}) bool {
+			return key != 1
+		})
+		used, _ := subject.(cache.Inspector).Usage()
+		Expect(used).Should(Equal(uint(1)))
+		for _, key := range []interface{}{"x", "y", "z", "w"} {
+			_, _ = subject.Get(ignoreCtx, key)
+		}
+		Expect(subject.(cache.Inspector).Stats().Evictions).Should(BeZero())
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"w", "z", "y", "x", 1}))
+	})
+
+	It("grounds loads of matching keys", func() {
+		started, release := make(chan bool), make(chan bool)
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			started <- true
+			<-release
+			return key, nil
+		})
+		go func() {
+			_, _ = subject.Get(ignoreCtx, "tenant:1:slow")
+		}()
+		<-started
+		subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:1:")
+		close(release)
+		Eventually(func() cache.Stats {
+			return subject.(cache.Inspector).Stats()
+		}).Should(Equal(cache.Stats{Misses: 1}))
+		Consistently(func() []interface{} {
+			return cachedKeys(subject)
+		}, 20*time.Millisecond).Should(BeEmpty())
+	})
+
+	for _, indexed := range []bool{false, true} {
+		indexed := indexed
+		Context(fmt.Sprintf("by prefix, indexed: %v", indexed), func() {
+			BeforeEach(func() {
+				if indexed {
+					subject.(cache.PrefixInvalidater).IndexPrefixes()
+				}
+			})
+
+			It("invalidates string keys with the prefix", func() {
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:1:")
+				Expect(sortedCachedKeys(subject)).Should(Equal([]string{"1", "tenant:10:a", "tenant:2:a"}))
+				used, _ := subject.(cache.Inspector).Usage()
+				Expect(used).Should(Equal(uint(3)))
+			})
+
+			It("matches keys equal to the prefix", func() {
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:2:a")
+				Expect(sortedCachedKeys(subject)).Should(HaveLen(4))
+			})
+
+			It("matches prefixes ending part way through a key", func() {
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:1")
+				Expect(sortedCachedKeys(subject)).Should(Equal([]string{"1", "tenant:2:a"}))
+			})
+
+			It("ignores prefixes nothing starts with", func() {
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:3")
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:1:c")
+				Expect(sortedCachedKeys(subject)).Should(HaveLen(5))
+			})
+
+			It("invalidates every string key for an empty prefix", func() {
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("")
+				Expect(sortedCachedKeys(subject)).Should(Equal([]string{"1"}))
+			})
+
+			It("finds keys cached after others were evicted and invalidated", func() {
+				_, _ = subject.Get(ignoreCtx, "tenant:1:c")
+				subject.Invalidate("tenant:10:a")
+				_, _ = subject.Get(ignoreCtx, "tenant:100")
+				subject.(cache.PrefixInvalidater).InvalidatePrefix("tenant:10")
+				Expect(sortedCachedKeys(subject)).Should(Equal([]string{"1", "tenant:1:b", "tenant:1:c", "tenant:2:a"}))
+			})
+
+			It("works across shards", func() {
+				sharded := cache.NewShardedLRU(4, 100, itemSizer, echoMapper, nil)
+				for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+					_, _ = sharded.Get(ignoreCtx, key)
+				}
+				if indexed {
+					sharded.(cache.PrefixInvalidater).IndexPrefixes()
+				}
+				sharded.(cache.PrefixInvalidater).InvalidatePrefix("a:")
+				Expect(cachedKeys(sharded)).Should(Equal([]interface{}{"b:1"}))
+			})
+		})
+	}
+
+	It("finds the same keys with and without the index", func() {
+		random := rand.New(rand.NewSource(1))
+		randomKey := func() string {
+			b := make([]byte, 1+random.Intn(6))
+			for i := range b {
+				b[i] = "abc"[random.Intn(3)]
+			}
+			return string(b)
+		}
+		echo := func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return key, nil
+		}
+		scanned := cache.NewLRUItem(200, echo)
+		indexed := cache.NewLRUItem(200, echo)
+		indexed.(cache.PrefixInvalidater).IndexPrefixes()
+		for round := 0; round < 50; round++ {
+			for i := 0; i < 20; i++ {
+				key := randomKey()
+				_, _ = scanned.Get(ignoreCtx, key)
+				_, _ = indexed.Get(ignoreCtx, key)
+			}
+			prefix := randomKey()
+			if len(prefix) > 2 {
+				prefix = prefix[:2]
+			}
+			scanned.(cache.PrefixInvalidater).InvalidatePrefix(prefix)
+			indexed.(cache.PrefixInvalidater).InvalidatePrefix(prefix)
+			Expect(sortedCachedKeys(indexed)).Should(Equal(sortedCachedKeys(scanned)))
+		}
+	})
+})
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RemoteStore is a key-value server outside the process holding encoded values, such as Redis or memcached
+type RemoteStore interface {
+	// Get the encoded value for key, ok is false if the store does not have it
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Set the encoded value for key, expiring it after ttl, or whenever the store decides to if ttl is zero
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete the key, if the store has it
+	Delete(ctx context.Context, key string) error
+}
+
+type remote struct {
+	store       RemoteStore
+	codec       ValueCodec
+	ttl         time.Duration
+	valueMapper ValueMapper
+	onError     func(err error)
+
+	mu       sync.Mutex
+	inflight map[string]*flight
+}
+
+// NewRemote is a cache whose values are kept in a RemoteStore, shared by every process using it, rather than in
+// memory. Misses are loaded with valueMapper, once per process at a time, and written to the store.
+//
+// The store is a cache, not the source of truth, so failing to reach it, or to decode what it returns, is treated
+// as a miss and passed to onError, which may be nil. A store outage slows Get down rather than failing it.
+// Keys are sent as strings, keys of any other type are formatted with fmt.Sprint, so 1 and "1" are the same key.
+//
+// ttl: how long values are kept in the store, unless the ValueMapper returns them WithTTL. Zero leaves it to the store.
+// Other annotations, such as tags, are ignored
+func NewRemote(store RemoteStore, codec ValueCodec, ttl time.Duration, valueMapper ValueMapper, onError func(err error)) GetInvalidater {
+	if onError == nil {
+		onError = func(err error) {}
+	}
+	return &remote{
+		store:       store,
+		codec:       codec,
+		ttl:         ttl,
+		valueMapper: valueMapper,
+		onError:     onError,
+		inflight:    make(map[string]*flight),
+	}
+}
+
+func remoteKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+func (r *remote) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	k := remoteKey(key)
+	data, ok, err := r.store.Get(ctx, k)
+	if err == nil && ok {
+		if value, err = r.codec.Decode(data); err == nil {
+			return value, nil
+		}
+		err = fmt.Errorf("failed to decode '%s': %w", k, err)
+	}
+	if err != nil {
+		r.onError(err)
+	}
+
+	r.mu.Lock()
+	f, loading := r.inflight[k]
+	if !loading {
+		f = newFlight()
+		r.inflight[k] = f
+	}
+	r.mu.Unlock()
+	if loading {
+		return f.wait(ctx)
+	}
+
+	value, err = safeLoad(ctx, r.valueMapper, key)
+	value, notes := unannotate(value)
+	if err == nil {
+		r.keep(ctx, k, f, notes)
+	} else {
+		value = nil
+	}
+
+	r.mu.Lock()
+	if r.inflight[k] == f {
+		delete(r.inflight, k)
+	}
+	r.mu.Unlock()
+	f.value, f.err = value, err
+	close(f.done)
+	return
+}
+
+// keep writes the loaded value to the store, unless it was invalidated while it loaded, or has already expired
+func (r *remote) keep(ctx context.Context, key string, f *flight, notes *annotated) {
+	ttl := r.ttl
+	if notes.expiresAt != 0 {
+		if ttl = time.Until(time.Unix(0, notes.expiresAt)); ttl <= 0 {
+			return
+		}
+	}
+	data, err := r.codec.Encode(notes.value)
+	if err != nil {
+		r.onError(fmt.Errorf("failed to encode '%s': %w", key, err))
+		return
+	}
+	r.mu.Lock()
+	invalidated := f.invalidated
+	r.mu.Unlock()
+	if invalidated {
+		return
+	}
+	if err = r.store.Set(ctx, key, data, ttl); err != nil {
+		r.onError(err)
+	}
+}
+
+// Invalidate deletes the key from the store, for every process using it
+func (r *remote) Invalidate(key interface{}) {
+	k := remoteKey(key)
+	r.mu.Lock()
+	if f, ok := r.inflight[k]; ok {
+		f.invalidated = true
+		delete(r.inflight, k)
+	}
+	r.mu.Unlock()
+	if err := r.store.Delete(context.Background(), k); err != nil {
+		r.onError(err)
+	}
+}
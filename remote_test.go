@@ -0,0 +1,168 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+)
+
+// mapStore is a RemoteStore in memory
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	down   bool
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{
+		values: make(map[string][]byte),
+		ttls:   make(map[string]time.Duration),
+	}
+}
+
+func (m *mapStore) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return nil, false, fmt.Errorf("store is down")
+	}
+	value, ok = m.values[key]
+	return
+}
+
+func (m *mapStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return fmt.Errorf("store is down")
+	}
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *mapStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+var _ = Describe("Remote", func() {
+	var (
+		mu      sync.Mutex
+		loads   []interface{}
+		errs    []error
+		store   *mapStore
+		mapper  cache.ValueMapper
+		subject cache.GetInvalidater
+	)
+	loaded := func() []interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]interface{}(nil), loads...)
+	}
+	BeforeEach(func() {
+		loads, errs = nil, nil
+		store = newMapStore()
+		mapper = func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			mu.Lock()
+			loads = append(loads, key)
+			mu.Unlock()
+			return fmt.Sprintf("value of %v", key), nil
+		}
+		subject = cache.NewRemote(store, cache.NewGobCodec(), time.Minute, mapper, func(err error) {
+			errs = append(errs, err)
+		})
+	})
+
+	It("shares loaded values through the store", func() {
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+		other := cache.NewRemote(store, cache.NewGobCodec(), time.Minute, mapper, nil)
+		Expect(other.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+		Expect(loaded()).Should(Equal([]interface{}{"a"}))
+		Expect(store.ttls).Should(HaveKeyWithValue("a", time.Minute))
+	})
+
+	It("formats keys that are not strings", func() {
+		_, _ = subject.Get(ignoreCtx, 1)
+		Expect(subject.Get(ignoreCtx, "1")).Should(Equal("value of 1"))
+		Expect(loaded()).Should(HaveLen(1))
+	})
+
+	It("deletes invalidated keys from the store", func() {
+		_, _ = subject.Get(ignoreCtx, "a")
+		subject.Invalidate("a")
+		Expect(store.values).ShouldNot(HaveKey("a"))
+		_, _ = subject.Get(ignoreCtx, "a")
+		Expect(loaded()).Should(HaveLen(2))
+	})
+
+	It("keeps values for the TTL they were loaded with", func() {
+		subject = cache.NewRemote(store, cache.NewGobCodec(), time.Minute, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return cache.WithTTL(key, time.Hour), nil
+		}, nil)
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(store.ttls["a"]).Should(BeNumerically("~", time.Hour, time.Minute))
+	})
+
+	It("loads the key when the store is down", func() {
+		store.down = true
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+		Expect(errs).Should(HaveLen(2))
+	})
+
+	It("loads the key when its stored value can't be decoded", func() {
+		store.values["a"] = []byte("not gob")
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+		Expect(errs).Should(HaveLen(1))
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+		Expect(loaded()).Should(HaveLen(1))
+	})
+
+	It("loads a key once for callers that miss at the same time", func() {
+		release := make(chan struct{})
+		subject = cache.NewRemote(store, cache.NewGobCodec(), 0, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			<-release
+			return mapper(ctx, key)
+		}, nil)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(subject.Get(ignoreCtx, "a")).Should(Equal("value of a"))
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		Expect(loaded()).Should(HaveLen(1))
+	})
+
+	It("does not store values invalidated while they load", func() {
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		subject = cache.NewRemote(store, cache.NewGobCodec(), 0, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			started <- struct{}{}
+			<-release
+			return "stale", nil
+		}, nil)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = subject.Get(ignoreCtx, "a")
+		}()
+		<-started
+		subject.Invalidate("a")
+		close(release)
+		<-done
+		Expect(store.values).ShouldNot(HaveKey("a"))
+	})
+})
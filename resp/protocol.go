@@ -0,0 +1,220 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+const (
+	// maxBulk is the longest bulk string accepted, the same as Redis
+	maxBulk = 512 << 20
+
+	// bulkChunk is the most of a bulk string allocated before its bytes arrive, so a length alone can't make the
+	// reader allocate up to maxBulk
+	bulkChunk = 64 << 10
+
+	// maxArray is the most elements an array may have
+	maxArray = 1 << 20
+
+	// arrayChunk is the most elements allocated for an array before they arrive
+	arrayChunk = 1 << 10
+
+	// maxInline is the longest inline command accepted
+	maxInline = 64 << 10
+
+	// maxDepth is how deeply arrays may be nested in a reply, so a malicious server can't overflow the stack
+	maxDepth = 32
+)
+
+var ErrProtocol = fmt.Errorf("malformed RESP")
+
+// Error is an error reply, such as "ERR unknown command"
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// readValue reads one RESP value: a string for simple strings, []byte for bulk strings, nil for null bulk strings and
+// arrays, int64 for integers, Error for errors and []interface{} for arrays
+func readValue(r *bufio.Reader) (value interface{}, err error) {
+	return readNested(r, 0)
+}
+
+// readNested reads a value found inside depth arrays
+func readNested(r *bufio.Reader, depth int) (value interface{}, err error) {
+	line, err := readLine(r)
+	if err != nil {
+		return
+	}
+	if len(line) == 0 {
+		return nil, ErrProtocol
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return parseInt(line[1:])
+	case '$':
+		return readBulk(r, line[1:])
+	case '*':
+		if depth >= maxDepth {
+			return nil, ErrProtocol
+		}
+		return readArray(r, line[1:], depth+1)
+	}
+	return nil, ErrProtocol
+}
+
+func readBulk(r *bufio.Reader, header []byte) (value interface{}, err error) {
+	n, err := parseInt(header)
+	if err != nil || n > maxBulk || n < -1 {
+		return nil, ErrProtocol
+	}
+	if n == -1 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(min64(n+2, bulkChunk)))
+	if _, err = io.CopyN(&buf, r, n+2); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	data := buf.Bytes()
+	if data[n] != '\r' || data[n+1] != '\n' {
+		return nil, ErrProtocol
+	}
+	return data[:n], nil
+}
+
+// readArray reads the elements of an array nested depth deep
+func readArray(r *bufio.Reader, header []byte, depth int) (value interface{}, err error) {
+	n, err := parseArrayLen(header)
+	if err != nil || n == -1 {
+		return nil, err
+	}
+	elements := make([]interface{}, 0, min64(n, arrayChunk))
+	for i := int64(0); i < n; i++ {
+		element, err := readNested(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+func parseArrayLen(header []byte) (n int64, err error) {
+	n, err = parseInt(header)
+	if err != nil || n > maxArray || n < -1 {
+		return 0, ErrProtocol
+	}
+	return
+}
+
+// readCommand reads a command as an array of bulk strings, or as an inline command, such as one typed into telnet.
+// Commands are never nested, so anything but a bulk string in the array is refused before it is read
+func readCommand(r *bufio.Reader) (args [][]byte, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] != '*' {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+	header, err := readLine(r)
+	if err != nil {
+		return
+	}
+	n, err := parseArrayLen(header[1:])
+	if err != nil || n == -1 {
+		return nil, err
+	}
+	args = make([][]byte, 0, min64(n, arrayChunk))
+	for i := int64(0); i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, ErrProtocol
+		}
+		arg, err := readBulk(r, line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if arg == nil {
+			return nil, ErrProtocol
+		}
+		args = append(args, arg.([]byte))
+	}
+	return
+}
+
+// readLine reads up to the next CRLF, which is not included
+func readLine(r *bufio.Reader) (line []byte, err error) {
+	line, err = lineserver.ReadLine(r, maxInline)
+	if err == lineserver.ErrLineTooLong {
+		err = ErrProtocol
+	}
+	return
+}
+
+func parseInt(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, ErrProtocol
+	}
+	return n, nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	_, _ = w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, message string) {
+	_, _ = w.WriteString("-" + message + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	_, _ = w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// writeBulk writes b as a bulk string, or a null bulk string if b is nil
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		_, _ = w.WriteString("$-1\r\n")
+		return
+	}
+	_, _ = w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	_, _ = w.Write(b)
+	_, _ = w.WriteString("\r\n")
+}
+
+// writeCommand writes the args as an array of bulk strings
+func writeCommand(w *bufio.Writer, args ...string) {
+	_, _ = w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		_, _ = w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
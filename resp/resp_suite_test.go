@@ -0,0 +1,13 @@
+package resp_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestResp(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Resp Suite")
+}
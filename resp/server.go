@@ -0,0 +1,163 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/internal/lineserver"
+)
+
+// Server serves a byte cache to Redis clients. It speaks enough of the Redis protocol for clients to read the cache:
+//
+//	GET key               the value, loaded with the cache's ByteMapper if it is not cached
+//	DEL key [key ...]     invalidates the keys, replying with how many were cached
+//	EXISTS key [key ...]  how many of the keys are cached, without loading them
+//	FLUSHDB               removes everything from the cache
+//	DBSIZE                how many values are cached
+//	INFO                  the cache's policy, usage and Stats
+//	PING, QUIT
+//
+// Keys are passed to the cache as strings. EXISTS and DEL's count need the cache to be a cache.Container, FLUSHDB a
+// cache.Clearer, and DBSIZE and INFO a cache.Inspector, which every cache in this module is
+type Server struct {
+	lineserver.Server
+	cache cache.ByteGetInvalidator
+}
+
+// NewServer serves the cache to clients connecting to the listener until Close is called
+func NewServer(listener net.Listener, c cache.ByteGetInvalidator) *Server {
+	s := &Server{cache: c}
+	lineserver.Start(&s.Server, listener, s.serve)
+	return s
+}
+
+// serve answers the client's commands in order until it quits, disconnects or sends something malformed
+func (s *Server) serve(ctx context.Context, r *bufio.Reader, w *bufio.Writer) {
+	for {
+		args, err := readCommand(r)
+		if err == ErrProtocol {
+			writeError(w, "ERR Protocol error")
+			_ = w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(ctx, w, strings.ToUpper(string(args[0])), args[1:])
+		// pipelined commands are answered together
+		if r.Buffered() == 0 || quit {
+			if w.Flush() != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// arities are how many arguments each command takes: -1 for one or more, -2 for any number
+var arities = map[string]int{
+	"GET":     1,
+	"DEL":     -1,
+	"EXISTS":  -1,
+	"FLUSHDB": 0,
+	"DBSIZE":  0,
+	"INFO":    -2,
+	"PING":    -2,
+	"QUIT":    0,
+}
+
+// execute the command and write its reply, quit is true if the client asked to disconnect
+func (s *Server) execute(ctx context.Context, w *bufio.Writer, name string, args [][]byte) (quit bool) {
+	n, ok := arities[name]
+	if !ok {
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", name))
+		return
+	}
+	if (n >= 0 && len(args) != n) || (n == -1 && len(args) == 0) {
+		writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+	switch name {
+	case "GET":
+		value, err := s.cache.Get(ctx, string(args[0]))
+		if err != nil {
+			writeError(w, "ERR "+lineserver.FirstLine(err.Error()))
+			return
+		}
+		if value == nil {
+			value = []byte{}
+		}
+		writeBulk(w, value)
+	case "DEL":
+		container, canTell := s.cache.(cache.Container)
+		var deleted int64
+		for _, key := range args {
+			if !canTell || container.Contains(string(key)) {
+				deleted++
+			}
+			s.cache.Invalidate(string(key))
+		}
+		writeInt(w, deleted)
+	case "EXISTS":
+		container, ok := s.cache.(cache.Container)
+		if !ok {
+			writeError(w, "ERR EXISTS is not supported by this cache")
+			return
+		}
+		var found int64
+		for _, key := range args {
+			if container.Contains(string(key)) {
+				found++
+			}
+		}
+		writeInt(w, found)
+	case "FLUSHDB":
+		clearer, ok := s.cache.(cache.Clearer)
+		if !ok {
+			writeError(w, "ERR FLUSHDB is not supported by this cache")
+			return
+		}
+		clearer.Clear()
+		writeSimple(w, "OK")
+	case "DBSIZE":
+		inspector, ok := s.cache.(cache.Inspector)
+		if !ok {
+			writeError(w, "ERR DBSIZE is not supported by this cache")
+			return
+		}
+		writeInt(w, int64(inspector.Stats().Items))
+	case "INFO":
+		writeBulk(w, []byte(s.info()))
+	case "PING":
+		if len(args) > 0 {
+			writeBulk(w, args[0])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	}
+	return
+}
+
+// info is INFO's reply, in the same "field:value" lines as Redis
+func (s *Server) info() string {
+	var b strings.Builder
+	b.WriteString("# Server\r\nredis_mode:standalone\r\n")
+	if inspector, ok := s.cache.(cache.Inspector); ok {
+		used, capacity := inspector.Usage()
+		stats := inspector.Stats()
+		fmt.Fprintf(&b, "\r\n# Cache\r\npolicy:%s\r\nused:%d\r\ncapacity:%d\r\n", inspector.Policy(), used, capacity)
+		fmt.Fprintf(&b, "\r\n# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\nload_errors:%d\r\nevicted_keys:%d\r\n",
+			stats.Hits, stats.Misses, stats.LoadErrors, stats.Evictions)
+		fmt.Fprintf(&b, "\r\n# Keyspace\r\ndb0:keys=%d\r\n", stats.Items)
+	}
+	return b.String()
+}
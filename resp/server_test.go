@@ -0,0 +1,199 @@
+package resp_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/resp"
+)
+
+// testClient sends commands and returns the raw replies, which are never arrays from the Server
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testClient) send(args ...string) {
+	command := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, arg := range args {
+		command += "$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n"
+	}
+	_, err := c.conn.Write([]byte(command))
+	Expect(err).ShouldNot(HaveOccurred())
+}
+
+func (c *testClient) reply() string {
+	line, err := c.r.ReadString('\n')
+	Expect(err).ShouldNot(HaveOccurred())
+	if line[0] != '$' || line == "$-1\r\n" {
+		return line
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	Expect(err).ShouldNot(HaveOccurred())
+	body := make([]byte, n+2)
+	_, err = io.ReadFull(c.r, body)
+	Expect(err).ShouldNot(HaveOccurred())
+	return line + string(body)
+}
+
+func (c *testClient) call(args ...string) string {
+	c.send(args...)
+	return c.reply()
+}
+
+var _ = Describe("Server", func() {
+	var (
+		loads   []string
+		server  *resp.Server
+		client  *testClient
+		connect func() *testClient
+	)
+	BeforeEach(func() {
+		loads = nil
+		values := cache.NewLRUByte(100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			loads = append(loads, key.(string))
+			if key == "broken" {
+				return nil, fmt.Errorf("origin is down\nfor maintenance")
+			}
+			// exactly as long as it is, byte caches measure values by their capacity
+			value = make([]byte, len("value of ")+len(key.(string)))
+			copy(value, "value of "+key.(string))
+			return value, nil
+		})
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		server = resp.NewServer(listener, values)
+		connect = func() *testClient {
+			conn, err := net.Dial("tcp", server.Addr().String())
+			Expect(err).ShouldNot(HaveOccurred())
+			return &testClient{
+				conn: conn,
+				r:    bufio.NewReader(conn),
+			}
+		}
+		client = connect()
+	})
+	AfterEach(func() {
+		_ = client.conn.Close()
+		Expect(server.Close()).Should(Succeed())
+	})
+
+	It("loads values on GET", func() {
+		Expect(client.call("GET", "a")).Should(Equal("$10\r\nvalue of a\r\n"))
+		Expect(client.call("GET", "a")).Should(Equal("$10\r\nvalue of a\r\n"))
+		Expect(loads).Should(Equal([]string{"a"}))
+	})
+
+	It("replies with load errors", func() {
+		Expect(client.call("GET", "broken")).Should(Equal("-ERR origin is down\r\n"))
+		Expect(client.call("PING")).Should(Equal("+PONG\r\n"))
+	})
+
+	It("tells which keys exist without loading them", func() {
+		client.call("GET", "a")
+		Expect(client.call("EXISTS", "a", "b", "a")).Should(Equal(":2\r\n"))
+		Expect(loads).Should(Equal([]string{"a"}))
+	})
+
+	It("invalidates keys on DEL", func() {
+		client.call("GET", "a")
+		Expect(client.call("DEL", "a", "b")).Should(Equal(":1\r\n"))
+		Expect(client.call("EXISTS", "a")).Should(Equal(":0\r\n"))
+		client.call("GET", "a")
+		Expect(loads).Should(Equal([]string{"a", "a"}))
+	})
+
+	It("counts and flushes the values", func() {
+		client.call("GET", "a")
+		client.call("GET", "b")
+		Expect(client.call("DBSIZE")).Should(Equal(":2\r\n"))
+		Expect(client.call("FLUSHDB")).Should(Equal("+OK\r\n"))
+		Expect(client.call("DBSIZE")).Should(Equal(":0\r\n"))
+	})
+
+	It("describes the cache on INFO", func() {
+		client.call("GET", "a")
+		client.call("GET", "a")
+		info := client.call("INFO")
+		Expect(info).Should(ContainSubstring("policy:lru\r\n"))
+		Expect(info).Should(ContainSubstring("used:10\r\ncapacity:100\r\n"))
+		Expect(info).Should(ContainSubstring("keyspace_hits:1\r\nkeyspace_misses:1\r\n"))
+		Expect(info).Should(ContainSubstring("db0:keys=1\r\n"))
+	})
+
+	It("answers pipelined commands in order", func() {
+		client.send("GET", "a")
+		client.send("EXISTS", "a")
+		client.send("PING", "hello")
+		Expect(client.reply()).Should(Equal("$10\r\nvalue of a\r\n"))
+		Expect(client.reply()).Should(Equal(":1\r\n"))
+		Expect(client.reply()).Should(Equal("$5\r\nhello\r\n"))
+	})
+
+	It("accepts inline commands", func() {
+		_, err := client.conn.Write([]byte("GET a\r\n"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(client.reply()).Should(Equal("$10\r\nvalue of a\r\n"))
+	})
+
+	It("rejects unknown commands and wrong arguments", func() {
+		Expect(client.call("SET", "a", "1")).Should(Equal("-ERR unknown command 'SET'\r\n"))
+		Expect(client.call("GET")).Should(Equal("-ERR wrong number of arguments for 'get' command\r\n"))
+	})
+
+	It("disconnects clients sending malformed commands", func() {
+		_, err := client.conn.Write([]byte("*1\r\n:1\r\n"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(client.reply()).Should(Equal("-ERR Protocol error\r\n"))
+		_, err = client.r.ReadByte()
+		Expect(err).Should(Equal(io.EOF))
+	})
+
+	It("disconnects clients sending deeply nested arrays", func() {
+		// the server hangs up before reading it all, so the write may fail and the reply may be lost to the reset
+		go func() {
+			_, _ = client.conn.Write([]byte(strings.Repeat("*1\r\n", 3<<20)))
+		}()
+		replies, _ := ioutil.ReadAll(client.r)
+		Expect(string(replies)).Should(BeElementOf("", "-ERR Protocol error\r\n"))
+		Expect(connect().call("PING")).Should(Equal("+PONG\r\n"))
+	})
+
+	It("reads bulk strings longer than it allocates at once", func() {
+		message := strings.Repeat("0123456789", 20_000)
+		Expect(client.call("PING", message)).Should(Equal("$200000\r\n" + message + "\r\n"))
+	})
+
+	It("does not allocate bulk strings before they arrive", func() {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := client.conn.Write([]byte("*2\r\n$4\r\nPING\r\n$500000000\r\nshort"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(client.conn.(*net.TCPConn).CloseWrite()).Should(Succeed())
+		_, err = client.r.ReadByte()
+		Expect(err).Should(Equal(io.EOF))
+		runtime.ReadMemStats(&after)
+		Expect(after.TotalAlloc - before.TotalAlloc).Should(BeNumerically("<", 50<<20))
+	})
+
+	It("serves many clients", func() {
+		other := connect()
+		defer func() {
+			_ = other.conn.Close()
+		}()
+		client.call("GET", "a")
+		Expect(other.call("GET", "a")).Should(Equal("$10\r\nvalue of a\r\n"))
+		Expect(other.call("QUIT")).Should(Equal("+OK\r\n"))
+		Expect(loads).Should(Equal([]string{"a"}))
+	})
+})
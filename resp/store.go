@@ -0,0 +1,81 @@
+package resp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wojnosystems/go-cache/internal/connpool"
+)
+
+var ErrStoreClosed = connpool.ErrClosed
+
+// Store keeps encoded values in a Redis server, for cache.NewRemote. It keeps up to maxIdle connections open
+// between commands, and opens more when they are all in use
+type Store struct {
+	prefix string
+	conns  *connpool.Pool
+}
+
+// NewStore sends commands to the Redis server at addr, such as "10.0.0.1:6379".
+//
+// prefix: added to every key, so caches can share a server without their keys colliding
+// maxIdle: how many connections to keep open between commands
+func NewStore(addr string, prefix string, maxIdle int) *Store {
+	return &Store{
+		prefix: prefix,
+		conns:  connpool.New(addr, maxIdle),
+	}
+}
+
+func (s *Store) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	reply, err := s.do(ctx, "GET", s.prefix+key)
+	if err != nil || reply == nil {
+		return
+	}
+	if value, ok = reply.([]byte); !ok {
+		return nil, false, fmt.Errorf("GET replied with %T", reply)
+	}
+	return
+}
+
+// Set the value, with PX if ttl is positive. The TTL is rounded up to the millisecond
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	args := []string{"SET", s.prefix + key, string(value)}
+	if ttl > 0 {
+		ms := (ttl + time.Millisecond - 1) / time.Millisecond
+		args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+	}
+	_, err = s.do(ctx, args...)
+	return
+}
+
+func (s *Store) Delete(ctx context.Context, key string) (err error) {
+	_, err = s.do(ctx, "DEL", s.prefix+key)
+	return
+}
+
+// Close the idle connections, and those in use once their commands finish
+func (s *Store) Close() error {
+	return s.conns.Close()
+}
+
+// do sends the command and reads its reply. Error replies are returned as err
+func (s *Store) do(ctx context.Context, args ...string) (reply interface{}, err error) {
+	err = s.conns.Do(ctx, func(c *connpool.Conn) (err error) {
+		writeCommand(c.W, args...)
+		if err = c.W.Flush(); err != nil {
+			return
+		}
+		reply, err = readValue(c.R)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, isErr := reply.(Error); isErr {
+		return nil, replyErr
+	}
+	return
+}
@@ -0,0 +1,206 @@
+package resp_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/internal/fakeserver"
+	"github.com/wojnosystems/go-cache/resp"
+)
+
+// fakeRedis is an in-memory Redis server understanding GET, SET with PX, and DEL
+type fakeRedis struct {
+	*fakeserver.Server
+
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]string
+	commands int
+}
+
+func newFakeRedis() *fakeRedis {
+	f := &fakeRedis{
+		values: make(map[string]string),
+		ttls:   make(map[string]string),
+	}
+	var err error
+	f.Server, err = fakeserver.Start(f.serve)
+	Expect(err).ShouldNot(HaveOccurred())
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readArgs(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands++
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if value, ok := f.values[args[1]]; ok {
+				reply = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			f.values[args[1]] = args[2]
+			if len(args) == 5 {
+				f.ttls[args[1]] = args[3] + " " + args[4]
+			}
+			reply = "+OK\r\n"
+		case "DEL":
+			_, ok := f.values[args[1]]
+			delete(f.values, args[1])
+			if ok {
+				reply = ":1\r\n"
+			} else {
+				reply = ":0\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readArgs reads a command sent as an array of bulk strings
+func readArgs(r *bufio.Reader) (args []string, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		if line, err = r.ReadString('\n'); err != nil {
+			return
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, string(arg[:size]))
+	}
+	return
+}
+
+var _ = Describe("Store", func() {
+	var (
+		ctx     = context.Background()
+		server  *fakeRedis
+		store   *resp.Store
+		loads   int
+		subject cache.GetInvalidater
+	)
+	BeforeEach(func() {
+		server = newFakeRedis()
+		store = resp.NewStore(server.Addr(), "users:", 2)
+		loads = 0
+		subject = cache.NewRemote(store, cache.NewGobCodec(), 1500*time.Microsecond, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			loads++
+			return fmt.Sprintf("value of %v", key), nil
+		}, nil)
+	})
+	AfterEach(func() {
+		Expect(store.Close()).Should(Succeed())
+		Expect(server.Close()).Should(Succeed())
+	})
+
+	It("stores loaded values, with a prefix and TTL", func() {
+		Expect(subject.Get(ctx, "a")).Should(Equal("value of a"))
+		Expect(subject.Get(ctx, "a")).Should(Equal("value of a"))
+		Expect(loads).Should(Equal(1))
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		Expect(server.values).Should(HaveKey("users:a"))
+		Expect(server.ttls).Should(HaveKeyWithValue("users:a", "PX 2"))
+	})
+
+	It("deletes invalidated keys", func() {
+		_, _ = subject.Get(ctx, "a")
+		subject.Invalidate("a")
+		_, _ = subject.Get(ctx, "a")
+		Expect(loads).Should(Equal(2))
+	})
+
+	It("gets and sets binary values", func() {
+		value, ok, err := store.Get(ctx, "missing")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeFalse())
+		Expect(value).Should(BeNil())
+		Expect(store.Set(ctx, "b", []byte("binary\x00\r\nvalue"), 0)).Should(Succeed())
+		value, ok, err = store.Get(ctx, "b")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(value).Should(Equal([]byte("binary\x00\r\nvalue")))
+	})
+
+	It("loads the key when the server is down", func() {
+		down := resp.NewStore("127.0.0.1:1", "", 1)
+		subject = cache.NewRemote(down, cache.NewGobCodec(), 0, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return "loaded", nil
+		}, nil)
+		Expect(subject.Get(ctx, "a")).Should(Equal("loaded"))
+	})
+
+	It("refuses deeply nested replies", func() {
+		nesting, err := fakeserver.Start(func(conn net.Conn) {
+			if _, err := readArgs(bufio.NewReader(conn)); err == nil {
+				_, _ = conn.Write([]byte(strings.Repeat("*1\r\n", 1000) + ":1\r\n"))
+			}
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		defer func() {
+			_ = nesting.Close()
+		}()
+		reader := resp.NewStore(nesting.Addr(), "", 1)
+		defer func() {
+			_ = reader.Close()
+		}()
+		_, _, err = reader.Get(ctx, "a")
+		Expect(err).Should(MatchError(resp.ErrProtocol))
+	})
+
+	It("can be served by this module's own Server", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ShouldNot(HaveOccurred())
+		own := resp.NewServer(listener, cache.NewLRUByte(100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			return []byte("from the sidecar"), nil
+		}))
+		defer func() {
+			_ = own.Close()
+		}()
+		reader := resp.NewStore(own.Addr().String(), "", 1)
+		defer func() {
+			_ = reader.Close()
+		}()
+		value, ok, err := reader.Get(ctx, "a")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(value).Should(Equal([]byte("from the sidecar")))
+		Expect(reader.Set(ctx, "a", []byte("x"), 0)).Should(MatchError("ERR unknown command 'SET'"))
+	})
+})
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrorClassifier decides whether an error from a ValueMapper is worth acting on, e.g. retrying
+type ErrorClassifier func(err error) bool
+
+// IsTransient treats every error as transient, except the caller giving up or running out of time, and failures of
+// the cache itself, a value too large for it or a panic, which calling the backend again won't fix
+func IsTransient(err error) bool {
+	var panicErr *LoaderPanicError
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrInsufficientCapacity) && !errors.As(err, &panicErr)
+}
+
+// NewTimeoutMapper limits each load to timeout. The caller's ctx deadline still applies if it is sooner
+func NewTimeoutMapper(valueMapper ValueMapper, timeout time.Duration) ValueMapper {
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return valueMapper(ctx, key)
+	}
+}
+
+// RetryPolicy controls how NewRetryMapper retries failed loads
+type RetryPolicy struct {
+	// Attempts is the most times the load is tried, including the first. Less than 2 never retries
+	Attempts int
+
+	// InitialBackoff is how long to wait before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries, zero for no cap
+	MaxBackoff time.Duration
+
+	// Multiplier grows the backoff after each retry, values less than 1 are treated as 2
+	Multiplier float64
+
+	// Jitter randomly shortens each backoff by up to this fraction of it, from 0 to 1,
+	// so that many callers retrying the same backend spread out
+	Jitter float64
+
+	// Retryable is true for errors worth retrying, nil uses IsTransient
+	Retryable ErrorClassifier
+}
+
+// NewRetryMapper retries loads that fail with a retryable error, waiting an exponentially growing backoff
+// between attempts. It gives up early if ctx is done, returning the last error from the valueMapper
+func NewRetryMapper(valueMapper ValueMapper, policy RetryPolicy) ValueMapper {
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsTransient
+	}
+	return func(ctx context.Context, key interface{}) (value interface{}, err error) {
+		backoff := policy.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			value, err = valueMapper(ctx, key)
+			if err == nil || attempt >= policy.Attempts || !policy.Retryable(err) {
+				return
+			}
+			timer := time.NewTimer(policy.jitter(backoff))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			backoff = policy.next(backoff)
+		}
+	}
+}
+
+func (p RetryPolicy) jitter(backoff time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return backoff
+	}
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	return backoff - time.Duration(rand.Float64()*jitter*float64(backoff))
+}
+
+func (p RetryPolicy) next(backoff time.Duration) time.Duration {
+	backoff = time.Duration(float64(backoff) * p.Multiplier)
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,105 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"time"
+)
+
+// flakyMapper fails the first failures loads with err, then echoes keys
+type flakyMapper struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyMapper) Load(ctx context.Context, key interface{}) (value interface{}, err error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return key, nil
+}
+
+var _ = Describe("Retry", func() {
+	var (
+		source *flakyMapper
+		policy cache.RetryPolicy
+	)
+	BeforeEach(func() {
+		source = &flakyMapper{
+			err: intentionalErr,
+		}
+		policy = cache.RetryPolicy{
+			Attempts:       3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     5 * time.Millisecond,
+			Jitter:         0.5,
+		}
+	})
+
+	It("retries until it succeeds", func() {
+		source.failures = 2
+		subject := cache.NewRetryMapper(source.Load, policy)
+		Expect(subject(ignoreCtx, "1")).Should(Equal("1"))
+		Expect(source.calls).Should(Equal(3))
+	})
+
+	It("gives up after the attempts", func() {
+		source.failures = 5
+		subject := cache.NewRetryMapper(source.Load, policy)
+		_, err := subject(ignoreCtx, "1")
+		Expect(err).Should(MatchError(intentionalErr))
+		Expect(source.calls).Should(Equal(3))
+	})
+
+	It("does not retry errors that are not retryable", func() {
+		source.failures = 5
+		policy.Retryable = func(err error) bool {
+			return !errors.Is(err, intentionalErr)
+		}
+		subject := cache.NewRetryMapper(source.Load, policy)
+		_, _ = subject(ignoreCtx, "1")
+		Expect(source.calls).Should(Equal(1))
+	})
+
+	It("does not treat failures of the cache itself as transient", func() {
+		Expect(cache.IsTransient(intentionalErr)).Should(BeTrue())
+		Expect(cache.IsTransient(fmt.Errorf("wrapped: %w", context.DeadlineExceeded))).Should(BeFalse())
+		Expect(cache.IsTransient(cache.ErrInsufficientCapacity)).Should(BeFalse())
+		Expect(cache.IsTransient(fmt.Errorf("wrapped: %w", &cache.LoaderPanicError{Value: "boom"}))).Should(BeFalse())
+	})
+
+	It("stops waiting when the caller gives up", func() {
+		source.failures = 5
+		policy.InitialBackoff = time.Hour
+		subject := cache.NewRetryMapper(source.Load, policy)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := subject(ctx, "1")
+		Expect(err).Should(MatchError(intentionalErr))
+		Expect(source.calls).Should(Equal(1))
+	})
+
+	When("timing out", func() {
+		It("limits each load", func() {
+			subject := cache.NewTimeoutMapper(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}, 10*time.Millisecond)
+			_, err := subject(ignoreCtx, "1")
+			Expect(err).Should(MatchError(context.DeadlineExceeded))
+		})
+		It("does not retry timeouts by default", func() {
+			source.failures = 5
+			source.err = context.DeadlineExceeded
+			subject := cache.NewRetryMapper(source.Load, policy)
+			_, _ = subject(ignoreCtx, "1")
+			Expect(source.calls).Should(Equal(1))
+		})
+	})
+})
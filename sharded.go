@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/wojnosystems/go-cache/hashring"
+)
+
+// KeyHasher spreads keys across shards. Equal keys must always hash to the same value
+type KeyHasher func(key interface{}) uint64
+
+// HashKey is the default KeyHasher. Strings and integers are hashed directly, anything else is hashed by
+// its formatted type and value, which works for any comparable key but is slower. Provide your own KeyHasher
+// for struct keys on hot paths
+func HashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uint32:
+		return mix64(uint64(k))
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%T:%v", key, key)
+	return h.Sum64()
+}
+
+// hashString is FNV-1a, without the allocation of converting the string to bytes
+func hashString(s string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint64(s[i])
+		hash *= prime64
+	}
+	return hash
+}
+
+// mix64 is the splitmix64 finalizer, so sequential integers land on different shards
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// cacheShard is everything shardedCache needs from each of its shards
+type cacheShard interface {
+	inspectableCache
+	ManyGetter
+	notedGetter
+	entries() []keptEntry
+	restore(entries []keptEntry)
+}
+
+type shardedCache struct {
+	shards []cacheShard
+	hasher KeyHasher
+}
+
+// NewShardedLRU is NewLRU split into independent shards, each with its own lock, tracker and an even slice of cap.
+// Keys are assigned to shards by hasher, or HashKey if it is nil. Use it when many goroutines share one cache
+// and contention on a single lock is the bottleneck.
+//
+// Each shard evicts on its own, so eviction is least recently used within a shard rather than across the whole
+// cache, and no single value may be larger than cap / shardCount
+func NewShardedLRU(shardCount int, cap uint, valueSizer ValueSizer, valueMapper ValueMapper, hasher KeyHasher) ManyGetInvalidater {
+	return newShardedLRU(shardCount, cap, valueSizer, valueMapper, hasher)
+}
+
+func newShardedLRU(shardCount int, cap uint, valueSizer ValueSizer, valueMapper ValueMapper, hasher KeyHasher) *shardedCache {
+	return newShardedCache(shardCount, cap, hasher, func(shardCap uint) cacheShard {
+		return newLRU(shardCap, valueSizer, valueMapper)
+	})
+}
+
+// NewShardedLRUByte is NewLRUByte split into shards, like NewShardedLRU
+func NewShardedLRUByte(shardCount int, maxBytes uint, valueMapper ByteMapper, hasher KeyHasher) ByteGetInvalidator {
+	return &lruByte{
+		values: newShardedLRU(shardCount, maxBytes, byteLenFromInterface, valueMapperFromByte(valueMapper), hasher),
+	}
+}
+
+// newShardedCache splits cap evenly between shards made by newShard
+func newShardedCache(shardCount int, cap uint, hasher KeyHasher, newShard func(shardCap uint) cacheShard) *shardedCache {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	if hasher == nil {
+		hasher = HashKey
+	}
+	s := &shardedCache{
+		shards: make([]cacheShard, shardCount),
+		hasher: hasher,
+	}
+	for i := range s.shards {
+		shardCap := cap / uint(shardCount)
+		if uint(i) < cap%uint(shardCount) {
+			shardCap++
+		}
+		s.shards[i] = newShard(shardCap)
+	}
+	return s
+}
+
+func (s *shardedCache) shard(key interface{}) cacheShard {
+	return s.shards[hashring.Jump(s.hasher(key), len(s.shards))]
+}
+
+func (s *shardedCache) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return s.shard(key).Get(ctx, key)
+}
+
+func (s *shardedCache) getNoted(ctx context.Context, key interface{}) (notes *annotated, err error) {
+	return s.shard(key).getNoted(ctx, key)
+}
+
+// GetMany loads the misses with one call to the BulkValueMapper per shard
+func (s *shardedCache) GetMany(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+	byShard := make(map[cacheShard][]interface{})
+	for _, key := range keys {
+		shard := s.shard(key)
+		byShard[shard] = append(byShard[shard], key)
+	}
+	values = make(map[interface{}]interface{}, len(keys))
+	failed := make(KeyErrors)
+	for shard, shardKeys := range byShard {
+		shardValues, shardErr := shard.GetMany(ctx, shardKeys)
+		for key, value := range shardValues {
+			values[key] = value
+		}
+		if keyErrs, ok := shardErr.(KeyErrors); ok {
+			for key, keyErr := range keyErrs {
+				failed[key] = keyErr
+			}
+		}
+	}
+	if len(failed) != 0 {
+		err = failed
+	}
+	return
+}
+
+func (s *shardedCache) Contains(key interface{}) bool {
+	return s.shard(key).Contains(key)
+}
+
+func (s *shardedCache) Invalidate(key interface{}) {
+	s.shard(key).Invalidate(key)
+}
+
+func (s *shardedCache) InvalidateTag(tag interface{}) {
+	for _, shard := range s.shards {
+		shard.InvalidateTag(tag)
+	}
+}
+
+func (s *shardedCache) InvalidateWhere(matches func(key interface{}) bool) {
+	for _, shard := range s.shards {
+		shard.InvalidateWhere(matches)
+	}
+}
+
+func (s *shardedCache) InvalidatePrefix(prefix string) {
+	for _, shard := range s.shards {
+		shard.InvalidatePrefix(prefix)
+	}
+}
+
+func (s *shardedCache) IndexPrefixes() {
+	for _, shard := range s.shards {
+		shard.IndexPrefixes()
+	}
+}
+
+func (s *shardedCache) InvalidateAll() {
+	for _, shard := range s.shards {
+		shard.InvalidateAll()
+	}
+}
+
+func (s *shardedCache) InvalidateNamespace(namespace interface{}) {
+	for _, shard := range s.shards {
+		shard.InvalidateNamespace(namespace)
+	}
+}
+
+func (s *shardedCache) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// Save takes entries from each shard in turn. Shards don't share a recency order, so they are only approximately
+// the most recently used first
+func (s *shardedCache) Save(w io.Writer, codec ValueCodec) error {
+	perShard := make([][]keptEntry, len(s.shards))
+	remaining := 0
+	for i, shard := range s.shards {
+		perShard[i] = shard.entries()
+		remaining += len(perShard[i])
+	}
+	entries := make([]keptEntry, 0, remaining)
+	for depth := 0; len(entries) < remaining; depth++ {
+		for _, shardEntries := range perShard {
+			if depth < len(shardEntries) {
+				entries = append(entries, shardEntries[depth])
+			}
+		}
+	}
+	return writeSnapshot(w, codec, entries)
+}
+
+func (s *shardedCache) Load(r io.Reader, codec ValueCodec) error {
+	entries, err := readSnapshot(r, codec)
+	if err != nil {
+		return err
+	}
+	byShard := make(map[cacheShard][]keptEntry)
+	for _, entry := range entries {
+		shard := s.shard(entry.key)
+		byShard[shard] = append(byShard[shard], entry)
+	}
+	for shard, shardEntries := range byShard {
+		shard.restore(shardEntries)
+	}
+	return nil
+}
+
+func (s *shardedCache) Policy() string {
+	return "sharded lru"
+}
+
+func (s *shardedCache) Usage() (used uint, capacity uint) {
+	for _, shard := range s.shards {
+		shardUsed, shardCapacity := shard.Usage()
+		used += shardUsed
+		capacity += shardCapacity
+	}
+	return
+}
+
+// RecentKeys takes keys from each shard in turn. Shards don't share a recency order, so it is only approximately
+// the most recently used first
+func (s *shardedCache) RecentKeys(max int) (keys []interface{}) {
+	perShard := make([][]interface{}, len(s.shards))
+	for i, shard := range s.shards {
+		perShard[i] = shard.RecentKeys(max)
+	}
+	for depth := 0; len(keys) < max; depth++ {
+		added := false
+		for _, shardKeys := range perShard {
+			if depth < len(shardKeys) && len(keys) < max {
+				keys = append(keys, shardKeys[depth])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return
+}
+
+func (s *shardedCache) Stats() (stats Stats) {
+	for _, shard := range s.shards {
+		shardStats := shard.Stats()
+		stats.Hits += shardStats.Hits
+		stats.Misses += shardStats.Misses
+		stats.LoadErrors += shardStats.LoadErrors
+		stats.Evictions += shardStats.Evictions
+		stats.Items += shardStats.Items
+	}
+	return
+}
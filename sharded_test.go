@@ -0,0 +1,127 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strconv"
+	"testing"
+)
+
+func echoMapper(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return key, nil
+}
+
+func itemSizer(value interface{}) uint {
+	return 1
+}
+
+var _ = Describe("Sharded", func() {
+	var (
+		subject cache.ManyGetInvalidater
+	)
+	BeforeEach(func() {
+		subject = cache.NewShardedLRU(4, 10, itemSizer, echoMapper, nil)
+	})
+
+	It("splits the capacity between shards", func() {
+		_, capacity := subject.(cache.Inspector).Usage()
+		Expect(capacity).Should(Equal(uint(10)))
+	})
+
+	It("caches values", func() {
+		Expect(subject.Get(ignoreCtx, "1")).Should(Equal("1"))
+		Expect(subject.Get(ignoreCtx, "1")).Should(Equal("1"))
+		Expect(subject.(cache.Inspector).Stats()).Should(Equal(cache.Stats{
+			Hits:   1,
+			Misses: 1,
+			Items:  1,
+		}))
+	})
+
+	It("stays within capacity", func() {
+		for i := 0; i < 100; i++ {
+			_, _ = subject.Get(ignoreCtx, i)
+		}
+		used, _ := subject.(cache.Inspector).Usage()
+		Expect(used).Should(BeNumerically("<=", 10))
+		Expect(subject.(cache.Inspector).RecentKeys(100)).Should(HaveLen(int(used)))
+	})
+
+	It("gets many across shards", func() {
+		values, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2", "3", "4"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(values).Should(HaveLen(4))
+	})
+
+	It("invalidates and clears", func() {
+		_, _ = subject.Get(ignoreCtx, "1")
+		_, _ = subject.Get(ignoreCtx, "2")
+		subject.Invalidate("1")
+		Expect(subject.(cache.Inspector).Stats().Items).Should(Equal(1))
+		subject.(cache.Clearer).Clear()
+		Expect(subject.(cache.Inspector).Stats().Items).Should(BeZero())
+	})
+
+	It("uses the hasher", func() {
+		subject = cache.NewShardedLRU(2, 2, itemSizer, echoMapper, func(key interface{}) uint64 {
+			return 0
+		})
+		_, _ = subject.Get(ignoreCtx, "1")
+		_, _ = subject.Get(ignoreCtx, "2")
+		Expect(subject.(cache.Inspector).RecentKeys(10)).Should(Equal([]interface{}{"2"}))
+	})
+
+	When("bytes", func() {
+		It("limits bytes", func() {
+			byteCache := cache.NewShardedLRUByte(2, 100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+				return make([]byte, 40), nil
+			}, nil)
+			Expect(byteCache.Get(ignoreCtx, "1")).Should(HaveLen(40))
+			_, err := cache.NewShardedLRUByte(2, 100, func(ctx context.Context, key interface{}) (value []byte, err error) {
+				return make([]byte, 60), nil
+			}, nil).Get(ignoreCtx, "1")
+			Expect(err).Should(MatchError(cache.ErrInsufficientCapacity))
+		})
+	})
+
+	It("hashes keys consistently", func() {
+		Expect(cache.HashKey("a")).Should(Equal(cache.HashKey("a")))
+		Expect(cache.HashKey(1)).ShouldNot(Equal(cache.HashKey(2)))
+		type composite struct {
+			a int
+			b string
+		}
+		Expect(cache.HashKey(composite{1, "b"})).Should(Equal(cache.HashKey(composite{1, "b"})))
+		Expect(cache.HashKey(composite{1, "b"})).ShouldNot(Equal(cache.HashKey(composite{2, "b"})))
+	})
+})
+
+const benchmarkKeys = 1_024
+
+func benchmarkHits(b *testing.B, subject cache.Getter) {
+	keys := make([]interface{}, benchmarkKeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		_, _ = subject.Get(ignoreCtx, keys[i])
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = subject.Get(ignoreCtx, keys[i%benchmarkKeys])
+			i++
+		}
+	})
+}
+
+// Compare these with -cpu to see how each scales with GOMAXPROCS, e.g.
+// go test -run NONE -bench Hits -cpu 1,2,4,8,16
+func BenchmarkLRUHits(b *testing.B) {
+	benchmarkHits(b, cache.NewLRU(benchmarkKeys, itemSizer, echoMapper))
+}
+
+func BenchmarkShardedLRUHits(b *testing.B) {
+	benchmarkHits(b, cache.NewShardedLRU(64, benchmarkKeys*2, itemSizer, echoMapper, nil))
+}
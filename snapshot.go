@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// snapshotVersion changes whenever the snapshot format does
+const snapshotVersion = 1
+
+var ErrSnapshotVersion = fmt.Errorf("unsupported snapshot version")
+
+// Snapshotter saves what a cache holds, so a new cache can start warm instead of empty
+type Snapshotter interface {
+	// Save writes every cached entry to w, most recently used first, with values encoded by codec.
+	// Keys, tags and namespaces are encoded with encoding/gob, gob.Register any of their types that are not built in
+	Save(w io.Writer, codec ValueCodec) error
+
+	// Load caches the entries saved to r by Save, as if they had just been loaded, decoding values with codec.
+	// Entries that have expired are skipped. If they don't all fit, the most recently used are kept.
+	// Nothing is cached if r cannot be read to the end
+	Load(r io.Reader, codec ValueCodec) error
+}
+
+type snapshotHeader struct {
+	Version int
+}
+
+type snapshotEntry struct {
+	Key       interface{}
+	Value     []byte
+	Tags      []interface{}
+	Namespace interface{}
+	DependsOn []interface{}
+	ExpiresAt int64
+}
+
+// keptEntry is a cached value along with the annotations it was loaded with
+type keptEntry struct {
+	key   interface{}
+	notes *annotated
+}
+
+func (u *unbounded) Save(w io.Writer, codec ValueCodec) error {
+	return writeSnapshot(w, codec, u.entries())
+}
+
+func (u *unbounded) Load(r io.Reader, codec ValueCodec) error {
+	entries, err := readSnapshot(r, codec)
+	if err != nil {
+		return err
+	}
+	u.restore(entries)
+	return nil
+}
+
+// entries returns everything cached that is not stale, most recently used first
+func (u *unbounded) entries() (entries []keptEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.walk(func(key interface{}) bool {
+		s := u.stamps[key]
+		if u.stale(s) {
+			return true
+		}
+		entries = append(entries, keptEntry{
+			key:   key,
+			notes: u.notesLocked(key),
+		})
+		return true
+	})
+	return
+}
+
+// notesLocked rebuilds the annotations the key's value was cached with
+func (u *unbounded) notesLocked(key interface{}) *annotated {
+	s := u.stamps[key]
+	return &annotated{
+		value:     u.cache[key],
+		tags:      append([]interface{}(nil), u.tags.tagsByKey[key]...),
+		namespace: s.namespace,
+		dependsOn: append([]interface{}(nil), u.dependencies.tagsByKey[key]...),
+		expiresAt: s.expiresAt,
+	}
+}
+
+// restore caches the entries, least recently used first, so the most recently used are the last to be evicted
+func (u *unbounded) restore(entries []keptEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i := len(entries) - 1; i >= 0; i-- {
+		_ = u.keepLocked(entries[i].key, entries[i].notes, u.clock)
+	}
+}
+
+func writeSnapshot(w io.Writer, codec ValueCodec, entries []keptEntry) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		value, err := codec.Encode(entry.notes.value)
+		if err != nil {
+			return fmt.Errorf("failed to encode '%v': %w", entry.key, err)
+		}
+		err = enc.Encode(snapshotEntry{
+			Key:       entry.key,
+			Value:     value,
+			Tags:      entry.notes.tags,
+			Namespace: entry.notes.namespace,
+			DependsOn: entry.notes.dependsOn,
+			ExpiresAt: entry.notes.expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode '%v': %w", entry.key, err)
+		}
+	}
+	return nil
+}
+
+func readSnapshot(r io.Reader, codec ValueCodec) (entries []keptEntry, err error) {
+	dec := gob.NewDecoder(r)
+	var header snapshotHeader
+	if err = dec.Decode(&header); err != nil {
+		return
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotVersion, header.Version)
+	}
+	for {
+		var saved snapshotEntry
+		if err = dec.Decode(&saved); err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+		var value interface{}
+		if value, err = codec.Decode(saved.Value); err != nil {
+			return nil, fmt.Errorf("failed to decode '%v': %w", saved.Key, err)
+		}
+		entries = append(entries, keptEntry{
+			key: saved.Key,
+			notes: &annotated{
+				value:     value,
+				tags:      saved.Tags,
+				namespace: saved.Namespace,
+				dependsOn: saved.DependsOn,
+				expiresAt: saved.ExpiresAt,
+			},
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"time"
+)
+
+type snapshotUser struct {
+	Name string
+	Age  int
+}
+
+func init() {
+	gob.Register(snapshotUser{})
+}
+
+var _ = Describe("Snapshots", func() {
+	var (
+		loads    int
+		mapper   cache.ValueMapper
+		subject  cache.GetInvalidater
+		snapshot *bytes.Buffer
+	)
+	BeforeEach(func() {
+		loads = 0
+		mapper = func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			loads++
+			return cache.WithTags(snapshotUser{Name: key.(string), Age: len(key.(string))}, "users"), nil
+		}
+		subject = cache.NewLRUItem(4, mapper)
+		for _, key := range []string{"a", "bb", "ccc", "dddd"} {
+			_, _ = subject.Get(ignoreCtx, key)
+		}
+		_, _ = subject.Get(ignoreCtx, "a")
+		snapshot = &bytes.Buffer{}
+	})
+
+	It("restores the entries in the same order", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		Expect(cachedKeys(restored)).Should(Equal([]interface{}{"a", "dddd", "ccc", "bb"}))
+		Expect(restored.Get(ignoreCtx, "ccc")).Should(Equal(snapshotUser{Name: "ccc", Age: 3}))
+		Expect(loads).Should(Equal(4))
+	})
+
+	It("keeps the most recently used entries that fit", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored := cache.NewLRUItem(2, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		Expect(cachedKeys(restored)).Should(Equal([]interface{}{"a", "dddd"}))
+	})
+
+	It("restores the tags", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored.(cache.TagInvalidater).InvalidateTag("users")
+		Expect(cachedKeys(restored)).Should(BeEmpty())
+	})
+
+	It("drops expired entries", func() {
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			if key == "short" {
+				return cache.WithTTL(key, 20*time.Millisecond), nil
+			}
+			return cache.WithTTL(key, time.Hour), nil
+		})
+		_, _ = subject.Get(ignoreCtx, "short")
+		_, _ = subject.Get(ignoreCtx, "long")
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		time.Sleep(30 * time.Millisecond)
+		restored := cache.NewUnbounded(mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		Expect(cachedKeys(restored)).Should(Equal([]interface{}{"long"}))
+	})
+
+	It("does not save invalidated entries", func() {
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		_, _ = subject.Get(ignoreCtx, "bb")
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		Expect(cachedKeys(restored)).Should(Equal([]interface{}{"bb"}))
+	})
+
+	It("encodes values as JSON", func() {
+		codec := cache.NewJSONCodec(func() interface{} {
+			return new(snapshotUser)
+		})
+		Expect(subject.(cache.Snapshotter).Save(snapshot, codec)).Should(Succeed())
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, codec)).Should(Succeed())
+		Expect(restored.Get(ignoreCtx, "bb")).Should(Equal(snapshotUser{Name: "bb", Age: 2}))
+		Expect(loads).Should(Equal(4))
+	})
+
+	It("decodes JSON without a type", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewJSONCodec(nil))).Should(Succeed())
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewJSONCodec(nil))).Should(Succeed())
+		Expect(restored.Get(ignoreCtx, "bb")).Should(Equal(map[string]interface{}{"Name": "bb", "Age": 2.0}))
+	})
+
+	It("stores byte slices as they are", func() {
+		byteMapper := func(ctx context.Context, key interface{}) (value []byte, err error) {
+			return []byte(key.(string)), nil
+		}
+		original := cache.NewLRUByte(10, byteMapper)
+		_, _ = original.Get(ignoreCtx, "abc")
+		_, _ = original.Get(ignoreCtx, "de")
+		Expect(original.(cache.Snapshotter).Save(snapshot, cache.NewBytesCodec())).Should(Succeed())
+		restored := cache.NewLRUByte(4, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			Fail("should have been restored")
+			return
+		})
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewBytesCodec())).Should(Succeed())
+		Expect(restored.Get(ignoreCtx, "de")).Should(Equal([]byte("de")))
+		Expect(cachedKeys(restored)).Should(Equal([]interface{}{"de"}))
+	})
+
+	It("fails to encode values the codec does not support", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewBytesCodec())).ShouldNot(Succeed())
+	})
+
+	It("restores nothing from a truncated snapshot", func() {
+		Expect(subject.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		truncated := bytes.NewReader(snapshot.Bytes()[:snapshot.Len()-5])
+		restored := cache.NewLRUItem(4, mapper)
+		Expect(restored.(cache.Snapshotter).Load(truncated, cache.NewGobCodec())).ShouldNot(Succeed())
+		Expect(cachedKeys(restored)).Should(BeEmpty())
+	})
+
+	It("rejects other versions", func() {
+		Expect(gob.NewEncoder(snapshot).Encode(struct{ Version int }{Version: 99})).Should(Succeed())
+		err := subject.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())
+		Expect(err).Should(MatchError(ContainSubstring(cache.ErrSnapshotVersion.Error())))
+	})
+
+	It("restores sharded caches", func() {
+		sharded := cache.NewShardedLRU(4, 100, itemSizer, echoMapper, nil)
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			_, _ = sharded.Get(ignoreCtx, key)
+		}
+		Expect(sharded.(cache.Snapshotter).Save(snapshot, cache.NewGobCodec())).Should(Succeed())
+		restored := cache.NewShardedLRU(4, 100, itemSizer, echoMapper, nil)
+		Expect(restored.(cache.Snapshotter).Load(snapshot, cache.NewGobCodec())).Should(Succeed())
+		Expect(sortedCachedKeys(restored)).Should(Equal([]string{"a", "b", "c", "d", "e"}))
+		Expect(restored.(cache.Inspector).Stats().Misses).Should(BeZero())
+	})
+})
@@ -0,0 +1,176 @@
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wojnosystems/go-cache"
+)
+
+var errNoQuery = fmt.Errorf("sqlcache caches only load through Query")
+
+// Rows are the scanned results of a query. Values hold what the driver returned for each column, such as int64,
+// float64, bool, []byte, string, time.Time or nil. They are shared by every caller served from the cache, so don't
+// modify them
+type Rows struct {
+	Columns []string
+	Values  [][]interface{}
+}
+
+// table is the key the results of queries reading a table depend on
+type table string
+
+// queryKey is the context key of the query the ValueMapper runs
+type queryKey struct{}
+
+type query struct {
+	text string
+	args []interface{}
+}
+
+// DB caches the results of queries run against a database, invalidating them when statements change the tables
+// they read
+type DB struct {
+	db     *sql.DB
+	ttl    time.Duration
+	values cache.GetInvalidater
+
+	mu     sync.RWMutex
+	tables map[string]bool
+}
+
+// NewDB caches the results of queries against db in the cache newCache builds, keyed on the query text and
+// arguments. Results expire after ttl, zero keeps them until they are invalidated or evicted.
+//
+// newCache must build one of this package's in-memory caches, such as cache.NewLRUItem or cache.NewShardedLRU, as
+// results are invalidated through their dependencies on tables. Caches that encode values, such as cache.NewRemote,
+// don't keep those dependencies, and may decode results as some other type than *Rows, which Query fails on.
+//
+// Register the tables whose changes should invalidate results with RegisterTables. Results of queries naming a
+// registered table depend on it, and Exec invalidates every registered table its statement names, along with those
+// results. Tables are found by name, ignoring case, quoting and schema, so a name in a string literal counts too.
+// Changes made other than through Exec, such as by triggers or other processes, must be announced with
+// InvalidateTables. Results of queries naming only unregistered tables are never invalidated, so with a ttl of zero
+// they are kept until they are evicted
+func NewDB(db *sql.DB, newCache cache.LayerFactory, ttl time.Duration) *DB {
+	d := &DB{
+		db:     db,
+		ttl:    ttl,
+		tables: make(map[string]bool),
+	}
+	d.values = newCache(d.load)
+	return d
+}
+
+// RegisterTables makes the tables invalidate the cached results of queries naming them when they change
+func (d *DB) RegisterTables(names ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range names {
+		d.tables[strings.ToLower(name)] = true
+	}
+}
+
+// Query returns the rows the query returns, from the cache if the same query with the same arguments was already
+// run and none of the registered tables it names have changed since. Concurrent misses for the same query run it
+// once. Errors are not cached
+func (d *DB) Query(ctx context.Context, text string, args ...interface{}) (rows *Rows, err error) {
+	key, err := cache.JSONKey(append([]interface{}{text}, args...))
+	if err != nil {
+		return nil, err
+	}
+	value, err := d.values.Get(context.WithValue(ctx, queryKey{}, query{text: text, args: args}), key)
+	if err != nil {
+		return nil, err
+	}
+	rows, ok := value.(*Rows)
+	if !ok {
+		return nil, fmt.Errorf("sqlcache cache returned %T rather than *Rows", value)
+	}
+	return rows, nil
+}
+
+// Exec runs the statement, then invalidates the registered tables it names, even if it failed, as it may have
+// changed them before failing
+func (d *DB) Exec(ctx context.Context, text string, args ...interface{}) (result sql.Result, err error) {
+	defer d.InvalidateTables(d.tablesIn(text)...)
+	return d.db.ExecContext(ctx, text, args...)
+}
+
+// InvalidateTables removes the cached results of every query naming the tables
+func (d *DB) InvalidateTables(names ...string) {
+	for _, name := range names {
+		d.values.Invalidate(table(strings.ToLower(name)))
+	}
+}
+
+// load is the ValueMapper, running the query in ctx
+func (d *DB) load(ctx context.Context, key interface{}) (value interface{}, err error) {
+	q, ok := ctx.Value(queryKey{}).(query)
+	if !ok {
+		return nil, errNoQuery
+	}
+	// a statement changing the tables while the query runs stops its rows being cached
+	var dependsOn []interface{}
+	for _, name := range d.tablesIn(q.text) {
+		dependsOn = append(dependsOn, table(name))
+	}
+	rows, err := d.query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	value = cache.WithDependencies(rows, dependsOn...)
+	if d.ttl > 0 {
+		value = cache.WithTTL(value, d.ttl)
+	}
+	return value, nil
+}
+
+func (d *DB) query(ctx context.Context, q query) (rows *Rows, err error) {
+	sqlRows, err := d.db.QueryContext(ctx, q.text, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sqlRows.Close() }()
+	rows = &Rows{}
+	if rows.Columns, err = sqlRows.Columns(); err != nil {
+		return nil, err
+	}
+	for sqlRows.Next() {
+		values := make([]interface{}, len(rows.Columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = sqlRows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		rows.Values = append(rows.Values, values)
+	}
+	return rows, sqlRows.Err()
+}
+
+// tablesIn are the registered tables the statement names
+func (d *DB) tablesIn(text string) (names []string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), notIdentifier) {
+		if d.tables[word] && !seen[word] {
+			seen[word] = true
+			names = append(names, word)
+		}
+	}
+	return
+}
+
+// notIdentifier separates the words of a statement, including the quotes around names and the dots between a schema
+// and a table
+func notIdentifier(r rune) bool {
+	return r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,153 @@
+package sqlcache_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/sqlcache"
+)
+
+func newLRU(valueMapper cache.ValueMapper) cache.GetInvalidater {
+	return cache.NewLRUItem(100, valueMapper)
+}
+
+// memoryStore is a cache.RemoteStore keeping values in a map
+type memoryStore map[string][]byte
+
+func (m memoryStore) Get(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	value, ok = m[key]
+	return
+}
+
+func (m memoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryStore) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+var _ = Describe("DB", func() {
+	var (
+		ctx      = context.Background()
+		dbs      int
+		db       *sql.DB
+		database *fakeDatabase
+		subject  *sqlcache.DB
+	)
+	BeforeEach(func() {
+		dbs++
+		db, database = openFake(fmt.Sprint("db", dbs))
+		subject = sqlcache.NewDB(db, newLRU, 0)
+		subject.RegisterTables("users", "Orders")
+		for _, stmt := range []string{"INSERT INTO users VALUES (?)", "INSERT INTO orders VALUES (?)"} {
+			_, err := db.Exec(stmt, "first")
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+	})
+	AfterEach(func() {
+		_ = db.Close()
+	})
+	names := func(query string, args ...interface{}) (names []interface{}) {
+		rows, err := subject.Query(ctx, query, args...)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rows.Columns).Should(Equal([]string{"name"}))
+		for _, row := range rows.Values {
+			names = append(names, row[0])
+		}
+		return
+	}
+
+	It("caches the rows of each query", func() {
+		Expect(names("SELECT name FROM users")).Should(Equal([]interface{}{"first"}))
+		Expect(names("SELECT name FROM users")).Should(Equal([]interface{}{"first"}))
+		Expect(database.Queries()).Should(Equal(1))
+	})
+	It("keys results on the arguments", func() {
+		Expect(names("SELECT name FROM users WHERE name = ?", "first")).Should(Equal([]interface{}{"first"}))
+		Expect(names("SELECT name FROM users WHERE name = ?", "second")).Should(BeEmpty())
+		Expect(names("SELECT name FROM users WHERE name = ?", "first")).Should(Equal([]interface{}{"first"}))
+		Expect(database.Queries()).Should(Equal(2))
+	})
+	It("invalidates queries naming a table Exec changes", func() {
+		names("SELECT name FROM users")
+		names("SELECT name FROM users WHERE name = ?", "second")
+		names("SELECT name FROM orders")
+		_, err := subject.Exec(ctx, "INSERT INTO users VALUES (?)", "second")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names("SELECT name FROM users")).Should(Equal([]interface{}{"first", "second"}))
+		Expect(names("SELECT name FROM users WHERE name = ?", "second")).Should(Equal([]interface{}{"second"}))
+		Expect(names("SELECT name FROM orders")).Should(Equal([]interface{}{"first"}))
+		Expect(database.Queries()).Should(Equal(5))
+	})
+	It("matches registered tables ignoring case", func() {
+		names("SELECT name FROM orders")
+		_, err := subject.Exec(ctx, "INSERT INTO orders VALUES (?)", "second")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names("SELECT name FROM orders")).Should(HaveLen(2))
+	})
+	It("does not invalidate for tables that are not registered", func() {
+		_, err := db.Exec("INSERT INTO audit VALUES (?)", "first")
+		Expect(err).ShouldNot(HaveOccurred())
+		names("SELECT name FROM audit")
+		_, err = subject.Exec(ctx, "INSERT INTO audit VALUES (?)", "second")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names("SELECT name FROM audit")).Should(HaveLen(1))
+	})
+	It("invalidates tables changed elsewhere", func() {
+		names("SELECT name FROM users")
+		_, err := db.Exec("INSERT INTO users VALUES (?)", "second")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(names("SELECT name FROM users")).Should(HaveLen(1))
+		subject.InvalidateTables("USERS")
+		Expect(names("SELECT name FROM users")).Should(HaveLen(2))
+	})
+	It("does not cache rows read while Exec changes their table", func() {
+		database.running, database.release = make(chan struct{}), make(chan struct{})
+		done := make(chan []interface{})
+		go func() {
+			defer GinkgoRecover()
+			done <- names("SELECT name FROM users")
+		}()
+		<-database.running
+		database.mu.Lock()
+		database.running = nil
+		database.mu.Unlock()
+		_, err := subject.Exec(ctx, "INSERT INTO users VALUES (?)", "second")
+		Expect(err).ShouldNot(HaveOccurred())
+		close(database.release)
+		<-done
+		Expect(names("SELECT name FROM users")).Should(HaveLen(2))
+	})
+	It("does not cache errors", func() {
+		_, err := subject.Query(ctx, "FAIL")
+		Expect(err).Should(HaveOccurred())
+		_, err = subject.Query(ctx, "FAIL")
+		Expect(err).Should(HaveOccurred())
+		Expect(database.Queries()).Should(Equal(2))
+	})
+	It("expires results after the ttl", func() {
+		subject = sqlcache.NewDB(db, newLRU, 20*time.Millisecond)
+		names("SELECT name FROM users")
+		names("SELECT name FROM users")
+		time.Sleep(30 * time.Millisecond)
+		names("SELECT name FROM users")
+		Expect(database.Queries()).Should(Equal(2))
+	})
+	It("fails rather than panics when the cache returns something other than rows", func() {
+		subject = sqlcache.NewDB(db, func(valueMapper cache.ValueMapper) cache.GetInvalidater {
+			return cache.NewRemote(memoryStore{}, cache.NewJSONCodec(nil), 0, valueMapper, nil)
+		}, 0)
+		_, err := subject.Query(ctx, "SELECT name FROM users")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = subject.Query(ctx, "SELECT name FROM users")
+		Expect(err).Should(MatchError(ContainSubstring("rather than *Rows")))
+	})
+})
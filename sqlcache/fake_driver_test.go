@@ -0,0 +1,151 @@
+package sqlcache_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver understands just enough SQL for the tests:
+//
+//	SELECT <column> FROM <table> [WHERE <column> = ?]
+//	INSERT INTO <table> VALUES (?)
+//	FAIL
+//
+// Every table has a single column, and each DSN is its own database
+type fakeDriver struct {
+	mu        sync.Mutex
+	databases map[string]*fakeDatabase
+}
+
+type fakeDatabase struct {
+	mu      sync.Mutex
+	tables  map[string][]driver.Value
+	queries int
+
+	// running is closed by queries as they start, and queries wait for release, if they are set
+	running chan struct{}
+	release chan struct{}
+}
+
+var fakes = &fakeDriver{databases: make(map[string]*fakeDatabase)}
+
+func init() {
+	sql.Register("sqlcache-fake", fakes)
+}
+
+// openFake opens a new, empty database
+func openFake(name string) (*sql.DB, *fakeDatabase) {
+	fakes.mu.Lock()
+	database := &fakeDatabase{tables: make(map[string][]driver.Value)}
+	fakes.databases[name] = database
+	fakes.mu.Unlock()
+	db, err := sql.Open("sqlcache-fake", name)
+	if err != nil {
+		panic(err)
+	}
+	return db, database
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	database, ok := d.databases[name]
+	if !ok {
+		return nil, fmt.Errorf("no database %q", name)
+	}
+	return &fakeConn{database: database}, nil
+}
+
+// Queries is how many queries ran, including those that failed
+func (f *fakeDatabase) Queries() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries
+}
+
+type fakeConn struct {
+	database *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{database: c.database, words: strings.Fields(query)}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type fakeStmt struct {
+	database *fakeDatabase
+	words    []string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if len(s.words) != 5 || s.words[0] != "INSERT" || len(args) != 1 {
+		return nil, fmt.Errorf("unsupported statement %q", strings.Join(s.words, " "))
+	}
+	s.database.mu.Lock()
+	defer s.database.mu.Unlock()
+	name := s.words[2]
+	s.database.tables[name] = append(s.database.tables[name], args[0])
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.database.mu.Lock()
+	s.database.queries++
+	if len(s.words) < 4 || s.words[0] != "SELECT" {
+		s.database.mu.Unlock()
+		return nil, fmt.Errorf("unsupported query %q", strings.Join(s.words, " "))
+	}
+	running, release := s.database.running, s.database.release
+	var values []driver.Value
+	for _, value := range s.database.tables[s.words[3]] {
+		if len(s.words) < 8 || value == args[0] {
+			values = append(values, value)
+		}
+	}
+	s.database.mu.Unlock()
+	if running != nil {
+		close(running)
+		<-release
+	}
+	return &fakeRows{column: s.words[1], values: values}, nil
+}
+
+type fakeRows struct {
+	column string
+	values []driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{r.column}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
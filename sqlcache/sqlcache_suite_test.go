@@ -0,0 +1,13 @@
+package sqlcache_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSqlcache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sqlcache Suite")
+}
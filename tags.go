@@ -0,0 +1,85 @@
+package cache
+
+// TagInvalidater drops groups of keys tagged with WithTags
+type TagInvalidater interface {
+	// InvalidateTag invalidates every cached key that was tagged with tag when it was loaded
+	InvalidateTag(tag interface{})
+}
+
+// WithTags tags a loaded value, so it can be invalidated along with everything else carrying one of the same tags
+// with InvalidateTag. Return it from your ValueMapper, or as a value from your BulkValueMapper, in place of the value
+// itself. Callers of Get receive the value, without the tags
+func WithTags(value interface{}, tags ...interface{}) interface{} {
+	a := annotate(value)
+	a.tags = append(append([]interface{}{}, a.tags...), tags...)
+	return a
+}
+
+// tagIndex finds the keys carrying each tag
+type tagIndex struct {
+	keysByTag map[interface{}]map[interface{}]bool
+	tagsByKey map[interface{}][]interface{}
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		keysByTag: make(map[interface{}]map[interface{}]bool),
+		tagsByKey: make(map[interface{}][]interface{}),
+	}
+}
+
+func (t *tagIndex) add(key interface{}, tags []interface{}) {
+	if len(tags) == 0 {
+		return
+	}
+	for _, tag := range tags {
+		keys, ok := t.keysByTag[tag]
+		if !ok {
+			keys = make(map[interface{}]bool)
+			t.keysByTag[tag] = keys
+		}
+		keys[key] = true
+	}
+	t.tagsByKey[key] = append(t.tagsByKey[key], tags...)
+}
+
+func (t *tagIndex) remove(key interface{}) {
+	for _, tag := range t.tagsByKey[key] {
+		keys := t.keysByTag[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(t.keysByTag, tag)
+		}
+	}
+	delete(t.tagsByKey, key)
+}
+
+func (t *tagIndex) keys(tag interface{}) (keys []interface{}) {
+	for key := range t.keysByTag[tag] {
+		keys = append(keys, key)
+	}
+	return
+}
+
+func (u *unbounded) InvalidateTag(tag interface{}) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.inflight) != 0 {
+		// loads in progress that tag their value with it must not cache what they loaded before it was invalidated
+		u.clock++
+		u.tagInvalidations[tag] = u.clock
+	}
+	for _, key := range u.tags.keys(tag) {
+		u.invalidateLocked(key)
+	}
+}
+
+// tagInvalidatedLocked is true if any of the tags were invalidated after loadedAt
+func (u *unbounded) tagInvalidatedLocked(loadedAt uint64, tags []interface{}) bool {
+	for _, tag := range tags {
+		if u.tagInvalidations[tag] > loadedAt {
+			return true
+		}
+	}
+	return false
+}
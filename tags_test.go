@@ -0,0 +1,140 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"strings"
+	"time"
+)
+
+// taggingMapper echoes the key, tagged with each part of the key before a ':'
+func taggingMapper(ctx context.Context, key interface{}) (value interface{}, err error) {
+	parts := strings.Split(key.(string), ":")
+	var tags []interface{}
+	for _, tag := range parts[:len(parts)-1] {
+		tags = append(tags, tag)
+	}
+	return cache.WithTags(key, tags...), nil
+}
+
+func taggingBulkMapper(ctx context.Context, keys []interface{}) (values map[interface{}]interface{}, err error) {
+	values = make(map[interface{}]interface{})
+	for _, key := range keys {
+		values[key], _ = taggingMapper(ctx, key)
+	}
+	return
+}
+
+func cachedKeys(subject interface{}) []interface{} {
+	return subject.(cache.Inspector).RecentKeys(100)
+}
+
+var _ = Describe("Tags", func() {
+	var (
+		subject cache.GetInvalidater
+	)
+	BeforeEach(func() {
+		subject = cache.NewLRUItem(3, taggingMapper)
+		_, _ = subject.Get(ignoreCtx, "user1:profile")
+		_, _ = subject.Get(ignoreCtx, "user1:feed")
+		_, _ = subject.Get(ignoreCtx, "user2:profile")
+	})
+
+	It("invalidates everything carrying the tag", func() {
+		subject.(cache.TagInvalidater).InvalidateTag("user1")
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"user2:profile"}))
+		used, _ := subject.(cache.Inspector).Usage()
+		Expect(used).Should(Equal(uint(1)))
+	})
+
+	It("ignores unknown tags", func() {
+		subject.(cache.TagInvalidater).InvalidateTag("user3")
+		Expect(cachedKeys(subject)).Should(HaveLen(3))
+	})
+
+	It("forgets the tags of evicted keys", func() {
+		_, _ = subject.Get(ignoreCtx, "user3:profile")
+		subject.(cache.TagInvalidater).InvalidateTag("user1")
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"user3:profile", "user2:profile"}))
+	})
+
+	It("forgets the tags of keys that are reloaded without them", func() {
+		untagged := true
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			untagged = !untagged
+			if !untagged {
+				return cache.WithTags(key, "tag"), nil
+			}
+			return key, nil
+		})
+		_, _ = subject.Get(ignoreCtx, "1")
+		subject.Invalidate("1")
+		_, _ = subject.Get(ignoreCtx, "1")
+		subject.(cache.TagInvalidater).InvalidateTag("tag")
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"1"}))
+	})
+
+	It("returns the value without its tags", func() {
+		Expect(subject.Get(ignoreCtx, "user1:profile")).Should(Equal("user1:profile"))
+		Expect(subject.Get(ignoreCtx, "user3:profile")).Should(Equal("user3:profile"))
+	})
+
+	It("keeps the tags of values tagged twice", func() {
+		subject = cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return cache.WithTags(cache.WithTags(key, "first"), "second"), nil
+		})
+		_, _ = subject.Get(ignoreCtx, "1")
+		subject.(cache.TagInvalidater).InvalidateTag("first")
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "1")
+		subject.(cache.TagInvalidater).InvalidateTag("second")
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+	})
+
+	It("tags bulk loads", func() {
+		bulk := cache.NewLRUBulk(10, itemSizer, taggingBulkMapper)
+		_, _ = bulk.GetMany(ignoreCtx, []interface{}{"a:1", "b:1", "a:2"})
+		bulk.(cache.TagInvalidater).InvalidateTag("a")
+		Expect(cachedKeys(bulk)).Should(Equal([]interface{}{"b:1"}))
+	})
+
+	It("tags batched loads", func() {
+		batched := cache.NewLRUItem(10, cache.NewBatchingMapper(taggingBulkMapper, 10*time.Millisecond, 0))
+		getConcurrently(batched, "a:1", "b:1", "a:2")
+		batched.(cache.TagInvalidater).InvalidateTag("a")
+		Expect(cachedKeys(batched)).Should(Equal([]interface{}{"b:1"}))
+	})
+
+	It("tags sharded caches", func() {
+		sharded := cache.NewShardedLRU(4, 100, itemSizer, taggingMapper, nil)
+		for _, key := range []string{"a:1", "a:2", "a:3", "b:1"} {
+			_, _ = sharded.Get(ignoreCtx, key)
+		}
+		sharded.(cache.TagInvalidater).InvalidateTag("a")
+		Expect(cachedKeys(sharded)).Should(Equal([]interface{}{"b:1"}))
+	})
+
+	It("does not cache loads whose tags were invalidated while loading", func() {
+		started, release := make(chan bool, 1), make(chan bool)
+		subject = cache.NewLRUItem(3, func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			started <- true
+			<-release
+			return taggingMapper(ctx, key)
+		})
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			Expect(subject.Get(ignoreCtx, "user1:profile")).Should(Equal("user1:profile"))
+		}()
+		<-started
+		subject.(cache.TagInvalidater).InvalidateTag("user1")
+		close(release)
+		<-done
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "user1:profile")
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"user1:profile"}))
+	})
+
+})
@@ -0,0 +1,80 @@
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+)
+
+// taggedEchoMapper loads every key as itself, tagged "tag"
+func taggedEchoMapper(ctx context.Context, key interface{}) (value interface{}, err error) {
+	return cache.WithTags(key, "tag"), nil
+}
+
+var _ = Describe("Thread safety", func() {
+	caches := map[string]func() cache.GetInvalidater{
+		"unbounded":    func() cache.GetInvalidater { return cache.NewUnbounded(taggedEchoMapper) },
+		"lru":          func() cache.GetInvalidater { return cache.NewLRU(10, itemSizer, taggedEchoMapper) },
+		"lru item":     func() cache.GetInvalidater { return cache.NewLRUItem(10, taggedEchoMapper) },
+		"buffered lru": func() cache.GetInvalidater { return cache.NewBufferedLRU(10, itemSizer, taggedEchoMapper) },
+		"sharded lru": func() cache.GetInvalidater {
+			return cache.NewShardedLRU(4, 12, itemSizer, taggedEchoMapper, nil)
+		},
+		"sharded buffered lru": func() cache.GetInvalidater {
+			return cache.NewShardedBufferedLRU(4, 12, itemSizer, taggedEchoMapper, nil)
+		},
+	}
+	for name, newCache := range caches {
+		newCache := newCache
+		It("serves the right value from the "+name+" cache while other goroutines invalidate and inspect it", func() {
+			subject := newCache()
+			var wg sync.WaitGroup
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					for j := 0; j < 200; j++ {
+						key := strconv.Itoa((i + j) % 20)
+						Expect(subject.Get(ignoreCtx, key)).Should(Equal(key))
+						switch j % 10 {
+						case 0:
+							subject.Invalidate(key)
+						case 3:
+							if many, ok := subject.(cache.ManyGetter); ok {
+								values, err := many.GetMany(ignoreCtx, []interface{}{key, "many"})
+								Expect(err).ShouldNot(HaveOccurred())
+								Expect(values).Should(HaveKeyWithValue(key, key))
+							}
+						case 5:
+							if tagged, ok := subject.(cache.TagInvalidater); ok {
+								tagged.InvalidateTag("tag")
+							}
+						case 7:
+							if inspector, ok := subject.(cache.Inspector); ok {
+								_ = inspector.Stats()
+								_ = inspector.RecentKeys(5)
+								_, _ = inspector.Usage()
+							}
+						case 9:
+							if i == 0 {
+								subject.(cache.Clearer).Clear()
+							}
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+			if inspector, ok := subject.(cache.Inspector); ok {
+				used, capacity := inspector.Usage()
+				if capacity != 0 {
+					Expect(used).Should(BeNumerically("<=", capacity))
+				}
+			}
+		})
+	}
+})
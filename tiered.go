@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"strings"
+)
+
+// ByteTier is a larger, slower store behind a byte cache, such as a disk.Store
+type ByteTier interface {
+	Get(key string) (value []byte, ok bool)
+	Put(key string, value []byte) error
+	Delete(key string)
+	Keys() []string
+	Clear() error
+}
+
+type tieredLRUByte struct {
+	*lruByte
+	memory *lruBase
+	tier   ByteTier
+}
+
+// NewTieredLRUByte is NewLRUByte with a second tier behind the memory. Values evicted from memory are demoted to the
+// tier, and misses check the tier before calling valueMapper, moving what they find there back into memory.
+// A value is only ever in one of the two. The tier keeps its own capacity, and evicts from itself on its own.
+//
+// Only string keys are demoted, values for keys of any other type are evicted as usual. Invalidations remove keys
+// from both tiers, except for tags and namespaces, which byte values can't have. Snapshots only save the memory.
+// The tier is written to while the cache is locked, so it must be quick, such as a local disk
+func NewTieredLRUByte(maxBytes uint, tier ByteTier, valueMapper ByteMapper) ByteGetInvalidator {
+	t := &tieredLRUByte{
+		tier: tier,
+	}
+	t.memory = newLRU(maxBytes, byteLenFromInterface, valueMapperFromByte(t.promoting(valueMapper)))
+	t.memory.onEvict = t.demote
+	t.lruByte = &lruByte{
+		values: t.memory,
+	}
+	return t
+}
+
+// promoting checks the tier before calling valueMapper
+func (t *tieredLRUByte) promoting(valueMapper ByteMapper) ByteMapper {
+	return func(ctx context.Context, key interface{}) (value []byte, err error) {
+		if s, ok := key.(string); ok {
+			if value, ok = t.tier.Get(s); ok {
+				t.tier.Delete(s)
+				return value, nil
+			}
+		}
+		return valueMapper(ctx, key)
+	}
+}
+
+// demote the evicted value to the tier, unless it is stale
+func (t *tieredLRUByte) demote(key, value interface{}) {
+	s, ok := key.(string)
+	if !ok || t.memory.stale(t.memory.stamps[key]) {
+		return
+	}
+	_ = t.tier.Put(s, value.([]byte))
+}
+
+func (t *tieredLRUByte) Invalidate(key interface{}) {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	if s, ok := key.(string); ok {
+		t.tier.Delete(s)
+	}
+	t.memory.invalidateLocked(key)
+}
+
+func (t *tieredLRUByte) InvalidateWhere(matches func(key interface{}) bool) {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	for _, key := range t.tier.Keys() {
+		if matches(key) {
+			t.tier.Delete(key)
+		}
+	}
+	t.memory.invalidateWhereLocked(matches)
+}
+
+func (t *tieredLRUByte) InvalidatePrefix(prefix string) {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	for _, key := range t.tier.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			t.tier.Delete(key)
+		}
+	}
+	t.memory.invalidatePrefixLocked(prefix)
+}
+
+func (t *tieredLRUByte) InvalidateAll() {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	_ = t.tier.Clear()
+	t.memory.invalidateAllLocked()
+}
+
+func (t *tieredLRUByte) Clear() {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	_ = t.tier.Clear()
+	t.memory.clearLocked()
+}
+
+// Contains is true if the key is in either tier. Finding it in the tier counts as using it there
+func (t *tieredLRUByte) Contains(key interface{}) bool {
+	t.memory.mu.Lock()
+	defer t.memory.mu.Unlock()
+	if _, ok := t.memory.cache[key]; ok {
+		return !t.memory.stale(t.memory.stamps[key])
+	}
+	s, ok := key.(string)
+	if !ok {
+		return false
+	}
+	_, ok = t.tier.Get(s)
+	return ok
+}
+
+func (t *tieredLRUByte) Policy() string {
+	return "tiered lru"
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"github.com/wojnosystems/go-cache/disk"
+)
+
+var _ = Describe("Tiered LRU byte cache", func() {
+	var (
+		dir     string
+		store   *disk.Store
+		loads   []string
+		subject cache.ByteGetInvalidator
+	)
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "tiered")
+		Expect(err).ShouldNot(HaveOccurred())
+		store, err = disk.Open(dir, 100, 1024)
+		Expect(err).ShouldNot(HaveOccurred())
+		loads = nil
+		subject = cache.NewTieredLRUByte(4, store, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			loads = append(loads, key.(string))
+			value = make([]byte, len(key.(string)))
+			copy(value, key.(string))
+			return
+		})
+		for _, key := range []string{"aa", "bb", "cc"} {
+			_, _ = subject.Get(ignoreCtx, key)
+		}
+	})
+	AfterEach(func() {
+		Expect(store.Close()).Should(Succeed())
+		Expect(os.RemoveAll(dir)).Should(Succeed())
+	})
+	onDisk := func() []string {
+		keys := store.Keys()
+		sort.Strings(keys)
+		return keys
+	}
+
+	It("demotes evicted values to the disk", func() {
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"cc", "bb"}))
+		Expect(onDisk()).Should(Equal([]string{"aa"}))
+	})
+
+	It("promotes values from the disk instead of loading them", func() {
+		Expect(subject.Get(ignoreCtx, "aa")).Should(Equal([]byte("aa")))
+		Expect(loads).Should(Equal([]string{"aa", "bb", "cc"}))
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"aa", "cc"}))
+		Expect(onDisk()).Should(Equal([]string{"bb"}))
+	})
+
+	It("invalidates keys on the disk", func() {
+		subject.Invalidate("aa")
+		Expect(onDisk()).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "aa")
+		Expect(loads).Should(HaveLen(4))
+	})
+
+	It("invalidates prefixes and predicates on the disk", func() {
+		_, _ = subject.Get(ignoreCtx, "dd")
+		Expect(onDisk()).Should(Equal([]string{"aa", "bb"}))
+		subject.(cache.PrefixInvalidater).InvalidatePrefix("a")
+		Expect(onDisk()).Should(Equal([]string{"bb"}))
+		subject.(cache.PredicateInvalidater).InvalidateWhere(func(key interface{}) bool {
+			return key == "bb" || key == "dd"
+		})
+		Expect(onDisk()).Should(BeEmpty())
+		Expect(cachedKeys(subject)).Should(Equal([]interface{}{"cc"}))
+	})
+
+	It("contains keys in either tier", func() {
+		container := subject.(cache.Container)
+		Expect(container.Contains("aa")).Should(BeTrue())
+		Expect(container.Contains("cc")).Should(BeTrue())
+		Expect(container.Contains("zz")).Should(BeFalse())
+		Expect(loads).Should(HaveLen(3))
+	})
+
+	It("clears the disk", func() {
+		subject.(cache.Clearer).Clear()
+		Expect(onDisk()).Should(BeEmpty())
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+	})
+
+	It("does not demote values invalidated by InvalidateAll", func() {
+		subject.(cache.GenerationInvalidater).InvalidateAll()
+		Expect(onDisk()).Should(BeEmpty())
+		_, _ = subject.Get(ignoreCtx, "dd")
+		_, _ = subject.Get(ignoreCtx, "ee")
+		Expect(onDisk()).Should(BeEmpty())
+	})
+
+	It("keeps keys of other types in memory", func() {
+		subject = cache.NewTieredLRUByte(1, store, func(ctx context.Context, key interface{}) (value []byte, err error) {
+			return []byte{1}, nil
+		})
+		_, _ = subject.Get(ignoreCtx, 1)
+		_, _ = subject.Get(ignoreCtx, 2)
+		Expect(onDisk()).Should(Equal([]string{"aa"}))
+	})
+})
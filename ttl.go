@@ -0,0 +1,13 @@
+package cache
+
+import "time"
+
+// WithTTL expires a loaded value once ttl has passed. Return it from your ValueMapper, or as a value from your
+// BulkValueMapper, in place of the value itself. It may be combined with the other annotations, such as WithTags.
+// Expired values count as misses, and are removed when they are next looked up, or evicted as they go unused.
+// Callers of Get receive the value, without the TTL
+func WithTTL(value interface{}, ttl time.Duration) interface{} {
+	a := annotate(value)
+	a.expiresAt = time.Now().Add(ttl).UnixNano()
+	return a
+}
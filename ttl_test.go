@@ -0,0 +1,59 @@
+package cache_test
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wojnosystems/go-cache"
+	"sync/atomic"
+	"time"
+)
+
+var _ = Describe("TTL", func() {
+	var (
+		loads  *int32
+		mapper cache.ValueMapper
+	)
+	BeforeEach(func() {
+		loads = new(int32)
+		mapper = func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			atomic.AddInt32(loads, 1)
+			return cache.WithTTL(cache.WithTags(key, "tag"), 20*time.Millisecond), nil
+		}
+	})
+
+	It("reloads values once they expire", func() {
+		subject := cache.NewLRUItem(2, mapper)
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(1)))
+		time.Sleep(30 * time.Millisecond)
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(2)))
+	})
+
+	It("does not contain expired values", func() {
+		subject := cache.NewLRUItem(2, mapper)
+		_, _ = subject.Get(ignoreCtx, "a")
+		Expect(subject.(cache.Container).Contains("a")).Should(BeTrue())
+		time.Sleep(30 * time.Millisecond)
+		Expect(subject.(cache.Container).Contains("a")).Should(BeFalse())
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(1)))
+	})
+
+	It("expires buffered LRU hits", func() {
+		subject := cache.NewBufferedLRU(2, itemSizer, mapper)
+		_, _ = subject.Get(ignoreCtx, "a")
+		time.Sleep(30 * time.Millisecond)
+		_, _ = subject.Get(ignoreCtx, "a")
+		Expect(atomic.LoadInt32(loads)).Should(Equal(int32(2)))
+	})
+
+	It("does not cache values that expire before they are loaded", func() {
+		subject := cache.NewUnbounded(func(ctx context.Context, key interface{}) (value interface{}, err error) {
+			return cache.WithTTL(key, -time.Second), nil
+		})
+		Expect(subject.Get(ignoreCtx, "a")).Should(Equal("a"))
+		Expect(cachedKeys(subject)).Should(BeEmpty())
+	})
+})
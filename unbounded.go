@@ -1,12 +1,54 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"sync"
+)
 
 type stringKeyCache map[interface{}]interface{}
 
 type unbounded struct {
+	// invalidatedAt is read atomically, so it comes first to keep it 64-bit aligned
+	invalidatedAt uint64
+
+	// mu guards everything below. It is never held while calling the valueFactory or bulkFactory
+	mu           sync.Mutex
 	cache        stringKeyCache
 	valueFactory ValueMapper
+	bulkFactory  BulkValueMapper
+	inflight     map[interface{}]*flight
+	tags         *tagIndex
+	dependencies *tagIndex
+	prefixes     *radixNode
+	stats        Stats
+
+	// clock ticks on every invalidation. Loads are stamped with it when they start,
+	// entries loaded before the clock in invalidatedAt, or the one for their namespace, are stale.
+	// Only entries with a non-zero stamp are in stamps
+	clock      uint64
+	namespaces map[interface{}]*uint64
+	stamps     map[interface{}]stamp
+
+	// generationAt is the clock of the last InvalidateAll or InvalidateNamespace, sweptAt of the last sweep
+	// removing the entries they made stale
+	generationAt uint64
+	sweptAt      uint64
+
+	// invalidations and tagInvalidations hold when keys and tags were invalidated while loads were in progress,
+	// until none are
+	invalidations    map[interface{}]uint64
+	tagInvalidations map[interface{}]uint64
+	// invalidationsKept is how many invalidations were still needed the last time they were pruned
+	invalidationsKept int
+
+	// onHit, onStore and onRemove let caches built on top of unbounded keep their own bookkeeping, and walk
+	// visits the cached keys with the most recently used first, until fn returns false.
+	// They are always called with mu held.
+	// onStore may refuse to store a value by returning an error, which is passed on to the caller of Get
+	onHit    func(key interface{})
+	onStore  func(key, value interface{}) error
+	onRemove func(key, value interface{})
+	walk     func(fn func(key interface{}) bool)
 }
 
 // NewUnbounded creates a cache without any internal limits on how many items
@@ -18,25 +60,175 @@ func NewUnbounded(valueFactory ValueMapper) GetInvalidater {
 }
 
 func newUnbounded(valueFactory ValueMapper) *unbounded {
-	return &unbounded{
-		cache:        make(stringKeyCache),
-		valueFactory: valueFactory,
+	u := &unbounded{
+		cache:            make(stringKeyCache),
+		inflight:         make(map[interface{}]*flight),
+		tags:             newTagIndex(),
+		dependencies:     newTagIndex(),
+		namespaces:       make(map[interface{}]*uint64),
+		stamps:           make(map[interface{}]stamp),
+		invalidations:    make(map[interface{}]uint64),
+		tagInvalidations: make(map[interface{}]uint64),
+		valueFactory:     valueFactory,
+		bulkFactory:      bulkMapperFromValue(valueFactory),
+		onHit:            func(key interface{}) {},
+		onStore:          func(key, value interface{}) error { return nil },
+		onRemove:         func(key, value interface{}) {},
 	}
+	u.walk = u.walkMap
+	return u
 }
 
 func (u *unbounded) Get(ctx context.Context, key interface{}) (value interface{}, err error) {
-	var ok bool
-	if value, ok = u.cache[key]; ok {
+	value, ok, f, isNew := u.hitOrTakeOff(key)
+	if ok {
 		return
 	}
-	value, err = u.valueFactory(ctx, key)
-	if err != nil {
+	if !isNew {
+		return f.wait(ctx)
+	}
+
+	value, err = safeLoad(ctx, u.valueFactory, key)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.landLocked(key, f, value, err)
+}
+
+// hitOrTakeOff returns the cached value if there is one, otherwise the flight loading it, which is new if the caller
+// must load it. The lock is released even if a hook or the key's hash panics, so one bad key can't stop the cache
+func (u *unbounded) hitOrTakeOff(key interface{}) (value interface{}, ok bool, f *flight, isNew bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if value, ok = u.lookupLocked(key); ok {
+		u.stats.Hits++
+		u.onHit(key)
 		return
 	}
-	u.cache[key] = value
+	u.stats.Misses++
+	f, isNew = u.takeOffLocked(key)
 	return
 }
 
+// storeLocked caches the value, replacing any value another caller may have loaded in the meantime
+func (u *unbounded) storeLocked(key, value interface{}, s stamp) (err error) {
+	u.removeLocked(key)
+	if s != (stamp{}) {
+		u.stamps[key] = s
+	}
+	// onStore may refuse the value or panic, either way it is not cached and must not leave its stamp behind
+	stored := false
+	defer func() {
+		if !stored {
+			delete(u.stamps, key)
+		}
+	}()
+	if err = u.onStore(key, value); err != nil {
+		return err
+	}
+	stored = true
+	u.cache[key] = value
+	if s, ok := key.(string); ok && u.prefixes != nil {
+		u.prefixes.insert(s)
+	}
+	return nil
+}
+
+func (u *unbounded) removeLocked(key interface{}) {
+	if value, ok := u.cache[key]; ok {
+		delete(u.cache, key)
+		delete(u.stamps, key)
+		u.tags.remove(key)
+		u.dependencies.remove(key)
+		if s, ok := key.(string); ok && u.prefixes != nil {
+			u.prefixes.remove(s)
+		}
+		u.onRemove(key, value)
+	}
+}
+
+// walkMap visits the cached keys in no particular order, unbounded does not track how recently they were used
+func (u *unbounded) walkMap(fn func(key interface{}) bool) {
+	for key := range u.cache {
+		if !fn(key) {
+			return
+		}
+	}
+}
+
 func (u *unbounded) Invalidate(key interface{}) {
-	delete(u.cache, key)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.invalidateLocked(key)
+}
+
+func (u *unbounded) Clear() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.clearLocked()
+}
+
+func (u *unbounded) clearLocked() {
+	for key := range u.inflight {
+		u.groundLocked(key)
+	}
+	for key := range u.cache {
+		u.removeLocked(key)
+	}
+}
+
+func (u *unbounded) Policy() string {
+	return "unbounded"
+}
+
+func (u *unbounded) Usage() (used uint, capacity uint) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sweepLocked()
+	return uint(len(u.cache)), 0
+}
+
+// RecentKeys has no recency to go on, so keys come back in no particular order
+func (u *unbounded) RecentKeys(max int) (keys []interface{}) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sweepLocked()
+	for key := range u.cache {
+		if len(keys) >= max {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+func (u *unbounded) Stats() (stats Stats) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sweepLocked()
+	stats = u.stats
+	stats.Items = len(u.cache)
+	return
+}
+
+// peek returns the cached value without loading it or counting it as used
+func (u *unbounded) peek(key interface{}) (value interface{}, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	value, ok = u.cache[key]
+	return
+}
+
+func (u *unbounded) Contains(key interface{}) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_, ok := u.cache[key]
+	return ok && !u.stale(u.stamps[key])
+}
+
+// set caches the value as if it had just been loaded
+func (u *unbounded) set(key, value interface{}) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.storeLocked(key, value, u.stampLocked(u.clock, &annotated{}))
 }
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/wojnosystems/go-cache"
+	"sync"
 )
 
 func valueMapperWrap(source *MockstatefulValueMapper) cache.ValueMapper {
@@ -86,4 +87,117 @@ var _ = Describe("StringKey", func() {
 			_, _ = cacher.Get(ignoreCtx, "1")
 		})
 	})
+
+	When("many goroutines miss on the same key", func() {
+		var (
+			release chan struct{}
+		)
+		BeforeEach(func() {
+			release = make(chan struct{})
+			source.EXPECT().Get(gomock.Any(), "slow").Times(1).DoAndReturn(func(ctx context.Context, key string) (string, error) {
+				<-release
+				return "value", nil
+			})
+		})
+
+		It("loads it once", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(cacher.Get(ignoreCtx, "slow")).Should(Equal("value"))
+				}()
+			}
+			Eventually(func() uint64 {
+				return cacher.(cache.Inspector).Stats().Misses
+			}).Should(Equal(uint64(10)))
+			close(release)
+			wg.Wait()
+		})
+
+		It("does not cache a load invalidated while in flight", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(cacher.Get(ignoreCtx, "slow")).Should(Equal("value"))
+			}()
+			Eventually(func() uint64 {
+				return cacher.(cache.Inspector).Stats().Misses
+			}).Should(Equal(uint64(1)))
+			cacher.Invalidate("slow")
+			close(release)
+			<-done
+			Expect(cacher.(cache.Inspector).Stats().Items).Should(BeZero())
+		})
+	})
+
+	When("a key panics while the cache is locked", func() {
+		BeforeEach(func() {
+			source.EXPECT().Get(gomock.Any(), "1").Times(1).Return("1", nil)
+		})
+
+		It("stays usable after Get", func() {
+			Expect(func() { _, _ = cacher.Get(ignoreCtx, []int{1}) }).Should(Panic())
+			Expect(cacher.Get(ignoreCtx, "1")).Should(Equal("1"))
+		})
+		It("lands the flights GetMany took off before it", func() {
+			source.EXPECT().Get(gomock.Any(), "2").Times(1).Return("2", nil)
+			subject := cache.NewUnboundedBulk(func(ctx context.Context, keys []interface{}) (map[interface{}]interface{}, error) {
+				values := make(map[interface{}]interface{})
+				for _, key := range keys {
+					values[key], _ = source.Get(ctx, key.(string))
+				}
+				return values, nil
+			})
+			Expect(func() { _, _ = subject.GetMany(ignoreCtx, []interface{}{"1", []int{1}}) }).Should(Panic())
+			values, err := subject.GetMany(ignoreCtx, []interface{}{"1", "2"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(values).Should(Equal(map[interface{}]interface{}{"1": "1", "2": "2"}))
+		})
+	})
+
+	When("cleared", func() {
+		BeforeEach(func() {
+			source.EXPECT().Get(ignoreCtx, "1").Times(2).Return("1", nil)
+			source.EXPECT().Get(ignoreCtx, "2").Times(2).Return("2", nil)
+			_, _ = cacher.Get(ignoreCtx, "1")
+			_, _ = cacher.Get(ignoreCtx, "2")
+		})
+
+		It("fetches every item again", func() {
+			cacher.(cache.Clearer).Clear()
+			_, _ = cacher.Get(ignoreCtx, "1")
+			_, _ = cacher.Get(ignoreCtx, "2")
+		})
+	})
+
+	When("inspected", func() {
+		BeforeEach(func() {
+			source.EXPECT().Get(ignoreCtx, "1").Times(1).Return("1", nil)
+			source.EXPECT().Get(ignoreCtx, "2").Times(1).Return("", intentionalErr)
+			_, _ = cacher.Get(ignoreCtx, "1")
+			_, _ = cacher.Get(ignoreCtx, "1")
+			_, _ = cacher.Get(ignoreCtx, "2")
+		})
+
+		It("counts usage", func() {
+			Expect(cacher.(cache.Inspector).Stats()).Should(Equal(cache.Stats{
+				Hits:       1,
+				Misses:     2,
+				LoadErrors: 1,
+				Items:      1,
+			}))
+		})
+		It("is unbounded", func() {
+			inspector := cacher.(cache.Inspector)
+			Expect(inspector.Policy()).Should(Equal("unbounded"))
+			used, capacity := inspector.Usage()
+			Expect(used).Should(Equal(uint(1)))
+			Expect(capacity).Should(BeZero())
+			Expect(inspector.RecentKeys(10)).Should(ConsistOf("1"))
+		})
+	})
 })